@@ -0,0 +1,173 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func init() {
+	expvar.NewString("version").Set(version)
+
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+
+	expvar.Publish("timestamp", expvar.Func(func() any {
+		return time.Now().Unix()
+	}))
+
+	expvar.Publish("build_info", expvar.Func(func() any {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return nil
+		}
+
+		settings := make(map[string]string)
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+
+		return map[string]any{
+			"go_version": info.GoVersion,
+			"path":       info.Path,
+			"settings":   settings,
+		}
+	}))
+}
+
+// publishDBStats exposes the connection pool statistics of every database the
+// router knows about. It must only be called once per process.
+func publishDBStats(db *data.Router) {
+	expvar.Publish("database", expvar.Func(func() any {
+		return db.Stats()
+	}))
+}
+
+// adminRoutes serves operational endpoints. They are kept off the public API
+// port so they can be left unexposed outside the cluster.
+func (app *application) adminRoutes() http.Handler {
+	router := httprouter.New()
+
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	router.HandlerFunc(http.MethodGet, "/debug/vars", varsHandler)
+	router.HandlerFunc(http.MethodGet, "/debug/config", app.configHandler)
+	router.HandlerFunc(http.MethodGet, "/debug/log-level", app.showLogLevelHandler)
+	router.HandlerFunc(http.MethodPut, "/debug/log-level", app.updateLogLevelHandler)
+
+	if app.health != nil {
+		router.HandlerFunc(http.MethodGet, "/debug/health", app.healthDetailsHandler)
+	}
+
+	if app.metrics != nil {
+		router.Handler(http.MethodGet, "/metrics", app.metrics.Handler())
+	}
+
+	return app.recoverPanic(router)
+}
+
+// varsHandler serves the expvar variables like expvar.Handler, minus the
+// cmdline variable expvar publishes itself: os.Args holds any DSN or redis
+// URL given as a flag, and /debug/config already shows those redacted.
+func varsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprint(w, "{\n")
+
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+
+	fmt.Fprint(w, "\n}\n")
+}
+
+// configHandler shows the effective configuration and where each setting
+// came from, with credentials redacted.
+func (app *application) configHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"config": app.settings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{
+		"level":       app.logger.Level(),
+		"trace_level": app.logger.TraceLevel(),
+	}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateLogLevelHandler changes the log level, the trace level or both
+// until the process restarts, e.g. to turn on debug logging while
+// investigating an incident.
+func (app *application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Level      *string `json:"level"`
+		TraceLevel *string `json:"trace_level"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Level != nil || input.TraceLevel != nil, "level", "must be provided")
+
+	var level, traceLevel jsonlog.Level
+
+	if input.Level != nil {
+		level, err = jsonlog.ParseLevel(*input.Level)
+		v.Check(err == nil, "level", "must be one of debug, info, warn, error, fatal, off")
+	}
+
+	if input.TraceLevel != nil {
+		traceLevel, err = jsonlog.ParseLevel(*input.TraceLevel)
+		v.Check(err == nil, "trace_level", "must be one of debug, info, warn, error, fatal, off")
+	}
+
+	if v.Invalid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Level != nil {
+		app.logger.SetLevel(level)
+	}
+
+	if input.TraceLevel != nil {
+		app.logger.SetTraceLevel(traceLevel)
+	}
+
+	app.logger.Warn("log level changed",
+		jsonlog.Any("level", app.logger.Level()),
+		jsonlog.Any("trace_level", app.logger.TraceLevel()),
+	)
+
+	app.showLogLevelHandler(w, r)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appconfig "github.com/betasve/go-commerce/services/auth/internal/config"
+	"github.com/betasve/go-commerce/services/auth/internal/health"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminRoutesDebugVars(t *testing.T) {
+	app := application{}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+
+	app.adminRoutes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var vars map[string]json.RawMessage
+	err := json.NewDecoder(rr.Body).Decode(&vars)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"version", "goroutines", "build_info", "memstats"} {
+		assert.Contains(t, vars, key)
+	}
+
+	assert.NotContains(t, vars, "cmdline")
+}
+
+func TestAdminRoutesDebugConfig(t *testing.T) {
+	app := application{
+		settings: []appconfig.Setting{
+			{Name: "db-dsn", Value: "postgres://gcuser:xxxxx@db:5432/auth", Source: appconfig.SourceEnv},
+			{Name: "port", Value: "4000", Source: appconfig.SourceDefault},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+
+	app.adminRoutes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+
+	var body struct {
+		Config []appconfig.Setting `json:"config"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, app.settings, body.Config)
+}
+
+func TestAdminRoutesDebugHealth(t *testing.T) {
+	app := application{health: health.NewChecker(time.Second)}
+	app.health.Register("database", time.Second, func(context.Context) error {
+		return errors.New("dial tcp db:5432: connection refused")
+	})
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/health", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var body struct {
+		Health health.Report `json:"health"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, "dial tcp db:5432: connection refused", body.Health.Components["database"].Error)
+}
+
+func TestAdminRoutesLogLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		level      jsonlog.Level
+		traceLevel jsonlog.Level
+	}{
+		{"level", `{"level": "debug"}`, http.StatusOK, jsonlog.LevelDebug, jsonlog.LevelError},
+		{"trace level", `{"trace_level": "off"}`, http.StatusOK, jsonlog.LevelInfo, jsonlog.LevelOff},
+		{"both", `{"level": "WARN", "trace_level": "fatal"}`, http.StatusOK, jsonlog.LevelWarn, jsonlog.LevelFatal},
+		{"unknown level", `{"level": "verbose"}`, http.StatusUnprocessableEntity, jsonlog.LevelInfo, jsonlog.LevelError},
+		{"nothing", `{}`, http.StatusUnprocessableEntity, jsonlog.LevelInfo, jsonlog.LevelError},
+		{"malformed", `{"level":`, http.StatusBadRequest, jsonlog.LevelInfo, jsonlog.LevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := application{logger: jsonlog.New(io.Discard, jsonlog.LevelInfo)}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/debug/log-level", strings.NewReader(tt.body))
+
+			app.adminRoutes().ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.level, app.logger.Level())
+			assert.Equal(t, tt.traceLevel, app.logger.TraceLevel())
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			rr = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, "/debug/log-level", nil)
+
+			app.adminRoutes().ServeHTTP(rr, req)
+
+			var body struct {
+				Level      jsonlog.Level `json:"level"`
+				TraceLevel jsonlog.Level `json:"trace_level"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.level, body.Level)
+			assert.Equal(t, tt.traceLevel, body.TraceLevel)
+		})
+	}
+}
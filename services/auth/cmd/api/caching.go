@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+// weakETag identifies a JSON body. It is weak because the bytes on the wire
+// differ once the response is compressed.
+func weakETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+
+	return fmt.Sprintf(`W/"%016x"`, h.Sum64())
+}
+
+// notModified reports whether the validators in header satisfy the
+// conditional headers of r. If-Modified-Since is only considered without
+// If-None-Match, as RFC 9110 requires.
+func notModified(r *http.Request, header http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+
+			if tag == "*" || decodedETag(strings.TrimPrefix(tag, "W/")) == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
+
+// conditionalGET turns 200 responses to GET and HEAD requests into 304 Not
+// Modified when the client's copy is still current. Handlers only have to
+// set ETag or Last-Modified, which writeJSON does for the former.
+func (app *application) conditionalGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional := r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !conditional {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&conditionalResponseWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+type conditionalResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *conditionalResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if status == http.StatusOK && notModified(w.r, w.Header()) {
+		w.notModified = true
+
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *conditionalResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.notModified {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *conditionalResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
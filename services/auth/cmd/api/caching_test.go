@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		etag            string
+		lastModified    string
+		expected        bool
+	}{
+		{"Matching ETag", `"1"`, "", `"1"`, "", true},
+		{"Weak comparison", `W/"1"`, "", `"1"`, "", true},
+		{"One of several", `"0", "1"`, "", `"1"`, "", true},
+		{"Any", `*`, "", `"1"`, "", true},
+		{"Stale ETag", `"0"`, "", `"1"`, "", false},
+		{"ETag of a compressed response", `"1-gzip"`, "", `"1"`, "", true},
+		{"No ETag", `"1"`, "", "", "", false},
+		{"Unmodified", "", "Wed, 26 Mar 2025 15:04:05 GMT", "", "Wed, 26 Mar 2025 15:04:05 GMT", true},
+		{"Modified", "", "Wed, 26 Mar 2025 15:04:04 GMT", "", "Wed, 26 Mar 2025 15:04:05 GMT", false},
+		{"If-None-Match wins", `"0"`, "Wed, 26 Mar 2025 15:04:05 GMT", `"1"`, "Wed, 26 Mar 2025 15:04:05 GMT", false},
+		{"Invalid date", "", "yesterday", "", "Wed, 26 Mar 2025 15:04:05 GMT", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			if tc.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tc.ifModifiedSince)
+			}
+
+			header := make(http.Header)
+			if tc.etag != "" {
+				header.Set("ETag", tc.etag)
+			}
+			if tc.lastModified != "" {
+				header.Set("Last-Modified", tc.lastModified)
+			}
+
+			assert.Equal(t, tc.expected, notModified(req, header))
+		})
+	}
+}
+
+func TestConditionalGET(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models: newTestModels(t, true),
+	}
+
+	handler := app.routes()
+
+	do := func(method, url string, headers map[string]string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	show := do(http.MethodGet, "/v1/users/1", nil)
+	assert.Equal(t, http.StatusOK, show.Code)
+	assert.Equal(t, `"1"`, show.Header().Get("ETag"))
+	assert.Equal(t, "Wed, 26 Mar 2025 15:04:05 GMT", show.Header().Get("Last-Modified"))
+
+	rr := do(http.MethodGet, "/v1/users/1", map[string]string{"If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Equal(t, `"1"`, rr.Header().Get("ETag"))
+	assert.Empty(t, rr.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Body.String())
+
+	rr = do(http.MethodGet, "/v1/users/1", map[string]string{"If-Modified-Since": "Wed, 26 Mar 2025 15:04:05 GMT"})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = do(http.MethodGet, "/v1/users/1", map[string]string{"If-None-Match": `"0"`})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	list := do(http.MethodGet, "/v1/users", nil)
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Regexp(t, `^W/"[0-9a-f]{16}"$`, list.Header().Get("ETag"))
+
+	rr = do(http.MethodGet, "/v1/users", map[string]string{"If-None-Match": list.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = do(http.MethodGet, "/v1/users?name=jane", map[string]string{"If-None-Match": list.Header().Get("ETag")})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = do(http.MethodGet, "/v1/users/2", map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestConditionalGETCompressed(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models: newTestModels(t, true),
+	}
+	app.config.compression.enabled = true
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", `"1-gzip"`)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Equal(t, `"1-gzip"`, rr.Header().Get("ETag"))
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Empty(t, rr.Body.String())
+}
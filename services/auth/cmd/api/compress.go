@@ -0,0 +1,240 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressible lists the content types worth compressing. Everything the
+// API serves is JSON apart from the docs page and its assets.
+var compressible = []string{"application/json", problemContentType, "text/html", "text/css", "text/javascript"}
+
+// negotiateEncoding picks br or gzip from Accept-Encoding by q-value,
+// preferring br on a tie, or returns "" when neither is acceptable.
+func negotiateEncoding(r *http.Request) string {
+	q := map[string]float64{}
+
+	for _, part := range strings.Split(strings.Join(r.Header.Values("Accept-Encoding"), ","), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+
+		q[coding] = weight
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, coding := range []string{"br", "gzip"} {
+		weight, ok := q[coding]
+		if !ok {
+			weight, ok = q["*"]
+		}
+
+		if ok && weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+
+	return best
+}
+
+// compress encodes response bodies of at least the configured minimum size
+// with the encoding the client prefers. Smaller bodies are sent as they are,
+// since compressing them saves less than it costs.
+func (app *application) compress(next http.Handler) http.Handler {
+	if !app.config.compression.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding, minSize: app.config.compression.minSize}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter holds back the status and the first minSize bytes
+// of the body, then decides whether to compress.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+
+	w.status = status
+
+	// A 304 has no body to compress, but has to repeat the ETag the 200
+	// would have carried, which is the encoded one.
+	if status == http.StatusNotModified {
+		if etag := w.Header().Get("ETag"); etag != "" {
+			w.Header().Set("ETag", encodedETag(etag, w.encoding))
+		}
+	}
+
+	if !w.worthCompressing() {
+		w.start(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Close sends whatever is still held back and finishes the encoding.
+func (w *compressResponseWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 {
+			return nil
+		}
+
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+
+	if w.enc != nil {
+		return w.enc.Close()
+	}
+
+	return nil
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// worthCompressing reports whether the response, going by its status and
+// headers, can be compressed at all.
+func (w *compressResponseWriter) worthCompressing() bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	for _, t := range compressible {
+		if mediaType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodedETag gives a strong etag its own value for the encoded body, since
+// a strong validator must change with the bytes on the wire. Weak tags
+// already allow for that and are left as they are.
+func encodedETag(etag, encoding string) string {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return etag
+	}
+
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// decodedETag undoes encodedETag, so that tags sent back by clients compare
+// against the ones handlers set.
+func decodedETag(etag string) string {
+	for _, encoding := range []string{"br", "gzip"} {
+		if trimmed, ok := strings.CutSuffix(etag, "-"+encoding+`"`); ok {
+			return trimmed + `"`
+		}
+	}
+
+	return etag
+}
+
+func (w *compressResponseWriter) start(compress bool) error {
+	w.decided = true
+
+	if compress {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+
+		if etag := w.Header().Get("ETag"); etag != "" {
+			w.Header().Set("ETag", encodedETag(etag, w.encoding))
+		}
+
+		switch w.encoding {
+		case "br":
+			w.enc = brotli.NewWriterLevel(w.ResponseWriter, brotli.DefaultCompression)
+		default:
+			w.enc = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
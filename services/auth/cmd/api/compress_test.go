@@ -0,0 +1,166 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"GZIP", "gzip"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.acceptEncoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			assert.Equal(t, tc.expected, negotiateEncoding(req))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := `{"users":"` + strings.Repeat("a", 2048) + `"}`
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		status           int
+		contentType      string
+		body             string
+		expectedEncoding string
+	}{
+		{"Gzip", "gzip", http.StatusOK, "application/json", large, "gzip"},
+		{"Brotli", "br, gzip", http.StatusOK, "application/json", large, "br"},
+		{"Problem", "gzip", http.StatusUnprocessableEntity, problemContentType, large, "gzip"},
+		{"Below the minimum size", "gzip", http.StatusOK, "application/json", `{"users":[]}`, ""},
+		{"Not accepted", "", http.StatusOK, "application/json", large, ""},
+		{"Not compressible", "gzip", http.StatusOK, "image/png", large, ""},
+		{"No content", "gzip", http.StatusNoContent, "application/json", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{}
+			app.config.compression.enabled = true
+			app.config.compression.minSize = 1024
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Header().Set("Content-Length", "100")
+				w.WriteHeader(tc.status)
+
+				// Written in pieces to exercise the buffering.
+				for chunk := range chunks(tc.body, 500) {
+					w.Write([]byte(chunk))
+				}
+			})
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+
+			app.compress(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.expectedEncoding, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+
+			var body io.Reader = rr.Body
+			switch tc.expectedEncoding {
+			case "gzip":
+				assert.Empty(t, rr.Header().Get("Content-Length"))
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "br":
+				body = brotli.NewReader(rr.Body)
+			}
+
+			decoded, err := io.ReadAll(body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.body, string(decoded))
+		})
+	}
+}
+
+// chunks splits s into chunks of at most n bytes.
+func chunks(s string, n int) func(func(string) bool) {
+	return func(yield func(string) bool) {
+		for len(s) > 0 {
+			end := min(n, len(s))
+			if !yield(s[:end]) {
+				return
+			}
+			s = s[end:]
+		}
+	}
+}
+
+func TestCompressVersionETag(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models: newTestModels(t, true),
+	}
+	app.config.compression.enabled = true
+
+	routes := app.routes()
+
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	plain := get("", "")
+	assert.Equal(t, http.StatusOK, plain.Code)
+	assert.Equal(t, `"1"`, plain.Header().Get("ETag"))
+
+	gzipped := get("gzip", "")
+	assert.Equal(t, http.StatusOK, gzipped.Code)
+	assert.Equal(t, "gzip", gzipped.Header().Get("Content-Encoding"))
+	assert.Equal(t, `"1-gzip"`, gzipped.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusNotModified, get("gzip", `"1-gzip"`).Code)
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/users/1", strings.NewReader(`{"name":"Johny Doe"}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-Match", gzipped.Header().Get("ETag"))
+
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"2-gzip"`, rr.Header().Get("ETag"))
+}
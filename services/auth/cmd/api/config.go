@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	appconfig "github.com/betasve/go-commerce/services/auth/internal/config"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/tlsconfig"
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/betasve/go-commerce/services/auth/pkg/tracing"
+)
+
+// configOptions layers the environment and a config file under the flags.
+// The aliases are the variables docker-compose sets.
+var configOptions = appconfig.Options{
+	EnvPrefix: "GO_COMMERCE_",
+	Aliases: map[string]string{
+		"APP_PORT":     "port",
+		"DATABASE_URL": "db-dsn",
+		"KAFKA_BROKER": "kafka-brokers",
+	},
+	FileFlag: "config",
+}
+
+// loadCommandConfig parses the flags of a subcommand such as migrate the way
+// main parses its own, so that -config, the environment and *_FILE secrets
+// reach it too. The config file is the one the server reads, so settings
+// the subcommand has no flag for are skipped.
+func loadCommandConfig(fs *flag.FlagSet, args []string) error {
+	fs.String("config", "", "YAML or TOML file with settings named like the flags; the environment and flags override it")
+
+	opts := configOptions
+	opts.IgnoreUnknown = true
+
+	_, err := appconfig.Load(fs, args, opts)
+
+	return err
+}
+
+// validateConfig checks the settings the flags cannot check on their own,
+// keyed by flag name.
+func validateConfig(v *validator.Validator, cfg config) {
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(cfg.adminPort >= 0 && cfg.adminPort <= 65535, "admin-port", "must be between 0 and 65535")
+	v.Check(cfg.adminPort != cfg.port, "admin-port", "must differ from -port")
+	v.Check(validator.In(cfg.env, "development", "staging", "production"), "env", "must be development, staging or production")
+	v.Check(validator.In(cfg.errorsFormat, "legacy", "problem"), "errors-format", "must be legacy or problem")
+
+	v.Check(cfg.tls.certFile != "" || cfg.tls.keyFile == "", "tls-cert-file", "must be provided with -tls-key-file")
+	v.Check(cfg.tls.keyFile != "" || cfg.tls.certFile == "", "tls-key-file", "must be provided with -tls-cert-file")
+	v.Check(validator.In(cfg.tls.clientAuth, tlsconfig.ClientAuthModes...), "tls-client-auth", "must be one of "+strings.Join(tlsconfig.ClientAuthModes, ", "))
+	v.Check(cfg.tls.clientAuth == tlsconfig.ClientAuthNone || cfg.tls.certFile != "", "tls-client-auth", "needs -tls-cert-file")
+	v.Check(cfg.tls.clientAuth == tlsconfig.ClientAuthNone || cfg.tls.clientCAFile != "", "tls-client-ca-file", "must be provided to verify client certificates")
+	v.Check(validator.In(cfg.tls.minVersion, tlsconfig.MinVersions...), "tls-min-version", "must be one of "+strings.Join(tlsconfig.MinVersions, ", "))
+	v.Check(cfg.tls.reloadInterval >= 0, "tls-reload-interval", "must not be negative")
+
+	v.Check(cfg.db.dsn != "" || cfg.db.memory, "db-dsn", "must be provided unless -db-memory is set")
+	v.Check(cfg.db.maxOpenConns > 0, "db-max-open-conns", "must be greater than zero")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleTime >= 0, "db-max-idle-time", "must not be negative")
+	v.Check(cfg.db.migrateTimeout > 0, "db-migrate-timeout", "must be greater than zero")
+	v.Check(cfg.db.replicaCheckInterval > 0 || len(cfg.db.replicaDSNs) == 0, "db-replica-check-interval", "must be greater than zero with read replicas")
+
+	v.Check(validator.In(cfg.limiter.backend, "memory", "redis"), "limiter-backend", "must be memory or redis")
+	v.Check(cfg.limiter.backend != "redis" || cfg.limiter.redisURL != "", "limiter-redis-url", "must be provided for the redis backend")
+
+	v.Check(cfg.cors.maxAge >= 0, "cors-max-age", "must not be negative")
+	v.Check(cfg.compression.minSize >= 0, "compress-min-size", "must not be negative")
+	v.Check(cfg.idempotency.ttl > 0, "idempotency-ttl", "must be greater than zero")
+	v.Check(cfg.idempotency.lockTimeout > 0, "idempotency-lock-timeout", "must be greater than zero")
+	v.Check(cfg.idempotency.ttl <= 0 || cfg.idempotency.lockTimeout <= cfg.idempotency.ttl, "idempotency-lock-timeout", "must not be longer than idempotency-ttl")
+
+	if _, err := logRedactor(cfg); err != nil {
+		v.AddError("log-redact-keys", "has an "+err.Error())
+	}
+
+	for _, name := range cfg.log.detectors {
+		_, ok := jsonlog.LookupDetector(name)
+		v.Check(ok, "log-redact-detectors", fmt.Sprintf("has an unknown detector %q", name))
+	}
+
+	v.Check(cfg.accessLog.sampleRate >= 0 && cfg.accessLog.sampleRate <= 1, "access-log-sample-rate", "must be between 0 and 1")
+	v.Check(cfg.accessLog.slowThreshold >= 0, "access-log-slow-threshold", "must not be negative")
+
+	v.Check(cfg.shutdown.timeout > 0, "shutdown-timeout", "must be greater than zero")
+	v.Check(cfg.shutdown.taskTimeout > 0, "shutdown-task-timeout", "must be greater than zero")
+
+	v.Check(cfg.health.cacheTTL >= 0, "health-cache-ttl", "must not be negative")
+	v.Check(cfg.health.checkTimeout > 0, "health-check-timeout", "must be greater than zero")
+	v.Check(cfg.health.drainDelay >= 0, "health-drain-delay", "must not be negative")
+	v.Check(cfg.health.drainDelay+cfg.shutdown.taskTimeout < cfg.shutdown.timeout, "shutdown-timeout", "must be longer than -health-drain-delay and -shutdown-task-timeout together")
+
+	v.Check(validator.In(cfg.tracing.exporter, tracing.Exporters...), "trace-exporter", "must be one of "+strings.Join(tracing.Exporters, ", "))
+	v.Check(cfg.tracing.sampleRatio >= 0 && cfg.tracing.sampleRatio <= 1, "trace-sample-ratio", "must be between 0 and 1")
+}
+
+// configErrors lists the validation errors one per line, sorted by flag.
+func configErrors(errors map[string]string) string {
+	lines := make([]string, 0, len(errors))
+	for name, message := range errors {
+		lines = append(lines, fmt.Sprintf("  -%s %s", name, message))
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// logRedactor redacts the default keys and any given with -log-redact-keys,
+// using every detector unless -log-redact-detectors picks some. Unknown
+// detectors are left to validateConfig.
+func logRedactor(cfg config) (*jsonlog.Redactor, error) {
+	detectors := jsonlog.Detectors
+
+	if cfg.log.detectors != nil {
+		detectors = nil
+
+		for _, name := range cfg.log.detectors {
+			if d, ok := jsonlog.LookupDetector(name); ok {
+				detectors = append(detectors, d)
+			}
+		}
+	}
+
+	keys := append(jsonlog.DefaultKeys[:len(jsonlog.DefaultKeys):len(jsonlog.DefaultKeys)], cfg.log.redactKeys...)
+
+	return jsonlog.NewRedactor(keys, detectors)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+// validConfig returns the defaults main would start with, plus a DSN.
+func validConfig() config {
+	var cfg config
+
+	cfg.port = 4000
+	cfg.adminPort = 4001
+	cfg.env = "development"
+	cfg.errorsFormat = "legacy"
+	cfg.db.dsn = "postgres://localhost/auth"
+	cfg.db.maxOpenConns = 25
+	cfg.db.migrateTimeout = time.Minute
+	cfg.limiter.backend = "memory"
+	cfg.idempotency.ttl = time.Hour
+	cfg.idempotency.lockTimeout = 2 * time.Minute
+	cfg.shutdown.timeout = 9 * time.Second
+	cfg.shutdown.taskTimeout = 3 * time.Second
+	cfg.health.checkTimeout = time.Second
+	cfg.health.drainDelay = 3 * time.Second
+	cfg.tls.clientAuth = "none"
+	cfg.tls.minVersion = "1.2"
+	cfg.tracing.exporter = "none"
+	cfg.tracing.sampleRatio = 1
+
+	return cfg
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		errors map[string]string
+	}{
+		{
+			name:   "valid",
+			modify: func(*config) {},
+			errors: map[string]string{},
+		},
+		{
+			name: "memory store without dsn",
+			modify: func(cfg *config) {
+				cfg.db.dsn = ""
+				cfg.db.memory = true
+			},
+			errors: map[string]string{},
+		},
+		{
+			name: "missing dsn",
+			modify: func(cfg *config) {
+				cfg.db.dsn = ""
+			},
+			errors: map[string]string{"db-dsn": "must be provided unless -db-memory is set"},
+		},
+		{
+			name: "redis backend without url",
+			modify: func(cfg *config) {
+				cfg.limiter.backend = "redis"
+			},
+			errors: map[string]string{"limiter-redis-url": "must be provided for the redis backend"},
+		},
+		{
+			name: "out of range",
+			modify: func(cfg *config) {
+				cfg.port = 70000
+				cfg.env = "prod"
+				cfg.tracing.sampleRatio = 1.5
+				cfg.idempotency.ttl = 0
+			},
+			errors: map[string]string{
+				"port":               "must be between 1 and 65535",
+				"env":                "must be development, staging or production",
+				"trace-sample-ratio": "must be between 0 and 1",
+				"idempotency-ttl":    "must be greater than zero",
+			},
+		},
+		{
+			name: "idempotency lock longer than the ttl",
+			modify: func(cfg *config) {
+				cfg.idempotency.lockTimeout = 2 * time.Hour
+			},
+			errors: map[string]string{"idempotency-lock-timeout": "must not be longer than idempotency-ttl"},
+		},
+		{
+			name: "shutdown phases longer than the shutdown",
+			modify: func(cfg *config) {
+				cfg.health.drainDelay = 5 * time.Second
+				cfg.shutdown.taskTimeout = 5 * time.Second
+			},
+			errors: map[string]string{"shutdown-timeout": "must be longer than -health-drain-delay and -shutdown-task-timeout together"},
+		},
+		{
+			name: "tls key without certificate",
+			modify: func(cfg *config) {
+				cfg.tls.keyFile = "tls.key"
+			},
+			errors: map[string]string{"tls-cert-file": "must be provided with -tls-key-file"},
+		},
+		{
+			name: "client certificates without ca",
+			modify: func(cfg *config) {
+				cfg.tls.certFile = "tls.crt"
+				cfg.tls.keyFile = "tls.key"
+				cfg.tls.clientAuth = "require"
+			},
+			errors: map[string]string{"tls-client-ca-file": "must be provided to verify client certificates"},
+		},
+		{
+			name: "client certificates without tls",
+			modify: func(cfg *config) {
+				cfg.tls.clientAuth = "optional"
+				cfg.tls.clientCAFile = "ca.crt"
+				cfg.tls.minVersion = "1.1"
+			},
+			errors: map[string]string{
+				"tls-client-auth": "needs -tls-cert-file",
+				"tls-min-version": "must be one of 1.2, 1.3",
+			},
+		},
+		{
+			name: "log redaction",
+			modify: func(cfg *config) {
+				cfg.log.redactKeys = []string{"ssn", "("}
+				cfg.log.detectors = []string{"email", "ssn"}
+			},
+			errors: map[string]string{
+				"log-redact-keys":      "has an invalid redaction key pattern \"(\": error parsing regexp: missing closing ): `(`",
+				"log-redact-detectors": "has an unknown detector \"ssn\"",
+			},
+		},
+		{
+			name: "same ports",
+			modify: func(cfg *config) {
+				cfg.adminPort = cfg.port
+			},
+			errors: map[string]string{"admin-port": "must differ from -port"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			v := validator.New()
+			validateConfig(v, cfg)
+
+			assert.Equal(t, tt.errors, v.Errors)
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	got := configErrors(map[string]string{
+		"port":   "must be between 1 and 65535",
+		"db-dsn": "must be provided unless -db-memory is set",
+	})
+
+	assert.Equal(t, "  -db-dsn must be provided unless -db-memory is set\n  -port must be between 1 and 65535", got)
+}
+
+func TestLogRedactor(t *testing.T) {
+	cfg := validConfig()
+
+	r, err := logRedactor(cfg)
+	assert.NoError(t, err)
+	assert.True(t, r.SensitiveKey("password"))
+	assert.Equal(t, "mail [REDACTED]", r.String("mail bob@example.org"))
+
+	cfg.log.redactKeys = []string{"ssn"}
+	cfg.log.detectors = []string{}
+
+	r, err = logRedactor(cfg)
+	assert.NoError(t, err)
+	assert.True(t, r.SensitiveKey("ssn"))
+	assert.True(t, r.SensitiveKey("password"))
+	assert.False(t, r.SensitiveKey("method"))
+	assert.False(t, r.SensitiveKey("status"))
+	assert.Equal(t, "mail bob@example.org", r.String("mail bob@example.org"))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const requestInfoContextKey = contextKey("requestInfo")
+
+// requestInfo is filled in while a request travels down the middleware chain
+// and read back by the access log once the response has been written. It is
+// a pointer so that inner handlers can update what outer middleware sees.
+type requestInfo struct {
+	route string
+}
+
+// routeOrUnmatched keeps requests that hit no route, e.g. scans for random
+// paths, under a single label.
+func (info *requestInfo) routeOrUnmatched() string {
+	if info.route == "" {
+		return "unmatched"
+	}
+
+	return info.route
+}
+
+// contextSetRequestInfo reuses the requestInfo an outer middleware already
+// added, so that every layer sees the same one.
+func (app *application) contextSetRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+		return r, info
+	}
+
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoContextKey, info)), info
+}
+
+// contextGetRequestInfo returns a throwaway value when the request did not go
+// through the access log, so callers never have to check for nil.
+func (app *application) contextGetRequestInfo(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+
+	return info
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/betasve/go-commerce/services/auth/internal/openapi"
+	"github.com/julienschmidt/httprouter"
+)
+
+// validateRequest checks the path parameters, query string and JSON body
+// against op before the handler sees them, answering violations like
+// failedValidationResponse. Bodies that are not valid JSON are left for the
+// handler, whose readJSON explains what is wrong with them.
+func (app *application) validateRequest(op *openapi.Operation, next http.Handler) http.Handler {
+	if op == nil {
+		return next
+	}
+
+	doc := spec()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs := make(map[string]string)
+
+		params := httprouter.ParamsFromContext(r.Context())
+		query := r.URL.Query()
+
+		for _, p := range op.Parameters {
+			p = doc.ResolveParameter(p)
+
+			var raw string
+			switch p.In {
+			case "path":
+				raw = params.ByName(p.Name)
+			case "query":
+				raw = query.Get(p.Name)
+			default:
+				// Headers are checked by the handlers, which answer a missing
+				// If-Match with 428 rather than 422.
+				continue
+			}
+
+			if raw == "" {
+				if p.Required {
+					errs[p.Name] = "must be provided"
+				}
+				continue
+			}
+
+			doc.ValidateString(p.Schema, raw, p.Name, errs)
+		}
+
+		if media, ok := requestBodyMedia(op); ok {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+			if v, ok := decodeJSON(body); err == nil && ok && len(body) <= maxBodyBytes {
+				doc.Validate(media.Schema, v, "", errs)
+			}
+		}
+
+		if len(errs) > 0 {
+			app.failedValidationResponse(w, r, errs)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestBodyMedia(op *openapi.Operation) (openapi.MediaType, bool) {
+	if op.RequestBody == nil {
+		return openapi.MediaType{}, false
+	}
+
+	media, ok := op.RequestBody.Content[jsonContentType]
+
+	return media, ok
+}
+
+// checkResponse replaces responses that drift from op with a 500 and logs
+// the difference. It buffers every response, so it only runs with
+// -contract-strict, which is meant for tests and development.
+func (app *application) checkResponse(op *openapi.Operation, next http.Handler) http.Handler {
+	if op == nil || !app.config.contract.strict {
+		return next
+	}
+
+	doc := spec()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		if err := responseDrift(doc, op, rec.status, rec.header, rec.body.Bytes()); err != nil {
+			app.serverErrorResponse(w, r, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+			return
+		}
+
+		for key, values := range rec.header {
+			w.Header()[key] = values
+		}
+
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// responseDrift returns an error describing how a response differs from what
+// op documents for its status.
+func responseDrift(doc *openapi.Document, op *openapi.Operation, status int, header http.Header, body []byte) error {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+
+	resp = doc.ResolveResponse(resp)
+
+	// net/http drops the body of these, whatever the handler wrote.
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		return nil
+	}
+
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d is documented without a body", status)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d is not documented as %q", status, mediaType)
+	}
+
+	if media.Schema == nil || !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+
+	v, ok := decodeJSON(body)
+	if !ok {
+		return fmt.Errorf("status %d body is not valid JSON", status)
+	}
+
+	errs := make(map[string]string)
+	doc.Validate(media.Schema, v, "", errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(errs))
+	for field, message := range errs {
+		fields = append(fields, field+" "+message)
+	}
+	sort.Strings(fields)
+
+	return fmt.Errorf("status %d body does not match the spec: %s", status, strings.Join(fields, "; "))
+}
+
+// decodeJSON decodes a single JSON value the way openapi.Validate expects.
+func decodeJSON(body []byte) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, false
+	}
+
+	return v, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
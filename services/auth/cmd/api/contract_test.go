@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/betasve/go-commerce/services/auth/internal/health"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+		expectedErrors     map[string]string
+	}{
+		{"Error on non-integer id", http.MethodGet, "/v1/users/abc", "", http.StatusUnprocessableEntity, map[string]string{"id": "must be an integer"}},
+		{"Error on id below one", http.MethodDelete, "/v1/users/0", "", http.StatusUnprocessableEntity, map[string]string{"id": "must be at least 1"}},
+		{"Error on non-integer page", http.MethodGet, "/v1/users?page=abc", "", http.StatusUnprocessableEntity, map[string]string{"page": "must be an integer"}},
+		{"Error on page size above the maximum", http.MethodGet, "/v1/users?page_size=500", "", http.StatusUnprocessableEntity, map[string]string{"page_size": "must be at most 100"}},
+		{"Error on missing field", http.MethodPost, "/v1/users", `{"name":"Jane Doe","email":"jane@example.com"}`, http.StatusUnprocessableEntity, map[string]string{"password": "must be provided"}},
+		{"Error on unknown field", http.MethodPost, "/v1/users", `{"name":"Jane Doe","email":"jane@example.com","password":"Password123","admin":true}`, http.StatusUnprocessableEntity, map[string]string{"admin": "is not allowed"}},
+		{"Error on wrong type", http.MethodPost, "/v1/users", `{"name":123,"email":"","password":"short"}`, http.StatusUnprocessableEntity, map[string]string{"name": "must be a string", "email": "can't be blank", "password": "must be at least 8 characters long"}},
+		{"Error on body that is not an object", http.MethodPatch, "/v1/users/1", `[]`, http.StatusUnprocessableEntity, map[string]string{"body": "must be an object"}},
+		{"Leaves malformed JSON to the handler", http.MethodPost, "/v1/users", `{"name":`, http.StatusBadRequest, nil},
+		{"Passes valid requests through", http.MethodGet, "/v1/users?page=1&sort=-email", "", http.StatusOK, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{
+				logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+				models: newTestModels(t, true),
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("If-Match", `"1"`)
+
+			app.routes().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if tc.expectedErrors != nil {
+				var body struct {
+					Error map[string]string `json:"error"`
+				}
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+				assert.Equal(t, tc.expectedErrors, body.Error)
+			}
+		})
+	}
+}
+
+// TestContractStrict drives every route through the strict response check,
+// which turns any response that drifts from apiSpec into a 500.
+func TestContractStrict(t *testing.T) {
+	logs := &bytes.Buffer{}
+
+	app := &application{
+		logger: jsonlog.New(logs, jsonlog.LevelInfo),
+		models: newTestModels(t, true),
+		health: health.NewChecker(0),
+	}
+	app.config.contract.strict = true
+
+	handler := app.routes()
+
+	do := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		assert.NotEqual(t, http.StatusInternalServerError, rr.Code, "%s %s: %s", method, url, logs.String())
+
+		return rr
+	}
+
+	problem := map[string]string{"Accept": problemContentType}
+
+	do(http.MethodGet, "/v1/health/live", "", nil)
+	do(http.MethodGet, "/v1/healthcheck", "", nil)
+	do(http.MethodGet, "/v1/health/ready", "", nil)
+	do(http.MethodGet, "/v1/openapi.json", "", nil)
+	do(http.MethodGet, "/v1/docs", "", nil)
+	do(http.MethodGet, "/v1/docs/swagger-ui.css", "", nil)
+	do(http.MethodGet, "/v1/docs/swagger-ui-bundle.js", "", nil)
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/v1/users", `{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`, nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/v1/users", `{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`, nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/v1/users", `{}`, problem).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/v1/users", ``, nil).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/users", "", nil).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/users?name=nobody", "", nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodGet, "/v1/users?sort=password", "", nil).Code)
+
+	show := do(http.MethodGet, "/v1/users/2", "", nil)
+	assert.Equal(t, http.StatusOK, show.Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/users/3", "", problem).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodGet, "/v1/users/abc", "", nil).Code)
+
+	assert.Equal(t, http.StatusPreconditionRequired, do(http.MethodPatch, "/v1/users/2", `{"name":"Jane Smith"}`, nil).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodPatch, "/v1/users/2", `{"name":"Jane Smith"}`, map[string]string{"If-Match": `"0"`}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPatch, "/v1/users/2", `{"name":"Jane Smith"}`, map[string]string{"If-Match": show.Header().Get("ETag")}).Code)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/users/2", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/v1/users/2", "", nil).Code)
+}
+
+func TestResponseDrift(t *testing.T) {
+	doc := &openapi.Document{}
+	op := &openapi.Operation{
+		Responses: map[string]*openapi.Response{
+			"200": {Content: jsonContent(&openapi.Schema{
+				Type:       "object",
+				Required:   []string{"id"},
+				Properties: map[string]*openapi.Schema{"id": {Type: "integer"}},
+			})},
+			"204": {},
+		},
+	}
+
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+
+	tests := []struct {
+		name          string
+		status        int
+		header        http.Header
+		body          string
+		expectedError string
+	}{
+		{"Matches the spec", http.StatusOK, jsonHeader, `{"id":1}`, ""},
+		{"Ignores the body of 204", http.StatusNoContent, nil, `{}`, ""},
+		{"Error on undocumented status", http.StatusCreated, jsonHeader, `{"id":1}`, "status 201 is not documented"},
+		{"Error on undocumented content type", http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, `1`, `status 200 is not documented as "text/plain"`},
+		{"Error on invalid JSON", http.StatusOK, jsonHeader, `{"id":`, "status 200 body is not valid JSON"},
+		{"Error on drifted body", http.StatusOK, jsonHeader, `{"id":"1"}`, "status 200 body does not match the spec: id must be an integer"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := responseDrift(doc, op, tc.status, tc.header, []byte(tc.body))
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
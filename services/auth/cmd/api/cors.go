@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The methods and request headers trusted origins may use, and the response
+// headers their scripts may read.
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete}
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "X-Request-ID"}
+	corsExposedHeaders = []string{"ETag", "Idempotent-Replayed", "Location", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Request-ID"}
+)
+
+// parseOrigin normalises a trusted origin from the configuration. It is
+// either exact, like https://shop.example.com, or matches every subdomain,
+// like https://*.example.com, which does not match example.com itself.
+func parseOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", fmt.Errorf("invalid origin %q, expected scheme://host[:port]", origin)
+	}
+
+	host := strings.TrimPrefix(u.Host, "*.")
+	if strings.Contains(host, "*") {
+		return "", fmt.Errorf("invalid origin %q, a wildcard is only allowed as the first label", origin)
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// trustedOrigin reports whether origin, as sent by a browser, matches one
+// of the parsed trusted origins.
+func (app *application) trustedOrigin(origin string) bool {
+	if origin == "" || origin == "null" {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+
+	for _, trusted := range app.config.cors.trustedOrigins {
+		scheme, host, ok := strings.Cut(trusted, "://*.")
+		if !ok {
+			if origin == trusted {
+				return true
+			}
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(origin, scheme+"://")
+		if ok && strings.HasSuffix(rest, "."+host) && !strings.ContainsAny(strings.TrimSuffix(rest, "."+host), "/:@") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enableCORS adds CORS headers for trusted origins and answers their
+// preflight requests, which would otherwise reach the router as OPTIONS.
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		origin := r.Header.Get("Origin")
+
+		if !app.trustedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if app.config.cors.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+
+			if maxAge := app.config.cors.maxAge; maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+
+		next.ServeHTTP(w, r)
+	})
+}
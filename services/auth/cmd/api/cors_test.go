@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOrigin(t *testing.T) {
+	tests := []struct {
+		origin        string
+		expected      string
+		expectedError bool
+	}{
+		{"https://shop.example.com", "https://shop.example.com", false},
+		{"HTTPS://Shop.Example.com:8443/", "https://shop.example.com:8443", false},
+		{"https://*.example.com", "https://*.example.com", false},
+		{"shop.example.com", "", true},
+		{"https://shop.example.com/path", "", true},
+		{"https://shop.*.example.com", "", true},
+		{"https://user@shop.example.com", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.origin, func(t *testing.T) {
+			origin, err := parseOrigin(tc.origin)
+
+			assert.Equal(t, tc.expected, origin)
+			assert.Equal(t, tc.expectedError, err != nil)
+		})
+	}
+}
+
+func TestEnableCORS(t *testing.T) {
+	tests := []struct {
+		name                string
+		method              string
+		origin              string
+		preflight           bool
+		credentials         bool
+		expectedStatusCode  int
+		expectedAllowOrigin string
+		expectedCredentials string
+		expectedMaxAge      string
+	}{
+		{"No origin", http.MethodGet, "", false, false, http.StatusOK, "", "", ""},
+		{"Untrusted origin", http.MethodGet, "https://evil.example.org", false, false, http.StatusOK, "", "", ""},
+		{"Exact origin", http.MethodGet, "https://shop.example.com", false, false, http.StatusOK, "https://shop.example.com", "", ""},
+		{"Wildcard subdomain", http.MethodGet, "https://eu.admin.example.net", false, false, http.StatusOK, "https://eu.admin.example.net", "", ""},
+		{"Wildcard does not match the apex", http.MethodGet, "https://example.net", false, false, http.StatusOK, "", "", ""},
+		{"Wildcard does not match another port", http.MethodGet, "https://admin.example.net:8443", false, false, http.StatusOK, "", "", ""},
+		{"Credentials", http.MethodGet, "https://shop.example.com", false, true, http.StatusOK, "https://shop.example.com", "true", ""},
+		{"Preflight", http.MethodOptions, "https://shop.example.com", true, false, http.StatusNoContent, "https://shop.example.com", "", "600"},
+		{"Untrusted preflight", http.MethodOptions, "https://evil.example.org", true, false, http.StatusOK, "", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelInfo)}
+			app.config.cors.trustedOrigins = []string{"https://shop.example.com", "https://*.example.net"}
+			app.config.cors.allowCredentials = tc.credentials
+			app.config.cors.maxAge = 10 * time.Minute
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/v1/health/live", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+
+			app.routes().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			assert.Equal(t, tc.expectedAllowOrigin, rr.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tc.expectedCredentials, rr.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, tc.expectedMaxAge, rr.Header().Get("Access-Control-Max-Age"))
+			assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rr.Header().Values("Vary"))
+
+			if tc.preflight && tc.expectedStatusCode == http.StatusNoContent {
+				assert.Equal(t, "GET, POST, PATCH, DELETE", rr.Header().Get("Access-Control-Allow-Methods"))
+				assert.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "If-Match")
+			}
+		})
+	}
+}
@@ -3,21 +3,45 @@ package main
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
 )
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.problemResponse(w, r, http.StatusBadRequest, problemBadRequest, err.Error())
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	app.problemResponse(w, r, http.StatusConflict, problemEditConflict, message)
 }
 
+// errorResponse is problemResponse for errors without a type of their own,
+// which RFC 7807 calls about:blank.
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
+	app.problemResponse(w, r, status, problemType{uri: "about:blank", title: http.StatusText(status)}, message)
+}
+
+// problemResponse writes message, a string or a map of field errors, either
+// as application/problem+json or in the original {"error": ...} envelope,
+// depending on wantsProblem.
+func (app *application) problemResponse(w http.ResponseWriter, r *http.Request, status int, typ problemType, message interface{}) {
+	var err error
+
+	w.Header().Add("Vary", "Accept")
+
+	if app.wantsProblem(r) {
+		err = app.writeProblem(w, r, status, typ, message)
+	} else {
+		env := envelope{"error": message}
+
+		if id := requestid.FromContext(r.Context()); id != "" {
+			env["request_id"] = id
+		}
+
+		err = app.writeJSON(w, status, env, nil)
+	}
 
-	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
@@ -25,36 +49,57 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 }
 
 func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+	app.problemResponse(w, r, http.StatusUnprocessableEntity, problemValidation, errors)
 }
 
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
+	app.logger.PrintError(err, app.logProperties(r, map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
-	})
+	}))
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
 
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.problemResponse(w, r, http.StatusNotFound, problemNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
 
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.problemResponse(w, r, http.StatusMethodNotAllowed, problemMethodNotAllowed, message)
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
 	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.problemResponse(w, r, http.StatusInternalServerError, problemServerError, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since it was last retrieved, please fetch it again"
+	app.problemResponse(w, r, http.StatusPreconditionFailed, problemPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must be conditional, please provide an If-Match header"
+	app.problemResponse(w, r, http.StatusPreconditionRequired, problemPreconditionRequired, message)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.problemResponse(w, r, http.StatusTooManyRequests, problemRateLimited, message)
+}
+
+func (app *application) idempotencyKeyInUseResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a request with this Idempotency-Key is still being processed, please retry later"
+	w.Header().Set("Retry-After", "1")
+	app.problemResponse(w, r, http.StatusConflict, problemIdempotencyKeyInUse, message)
+}
+
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this Idempotency-Key was already used for a different request"
+	app.problemResponse(w, r, http.StatusConflict, problemIdempotencyKeyReused, message)
 }
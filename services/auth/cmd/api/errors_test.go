@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,6 +29,20 @@ func TestLogError(t *testing.T) {
 	assert.Contains(t, rec.String(), "test error")
 }
 
+func TestLogErrorRedactsURL(t *testing.T) {
+	rec := bytes.NewBufferString("")
+	app := application{
+		logger: jsonlog.New(rec, jsonlog.LevelInfo),
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/users?reset_token=s3cr3t&email=bob@example.org", nil)
+
+	app.logError(req, errors.New("no user with email bob@example.org"))
+
+	assert.Contains(t, rec.String(), `/v1/users?reset_token=[REDACTED]\u0026email=[REDACTED]`)
+	assert.NotContains(t, rec.String(), "s3cr3t")
+	assert.NotContains(t, rec.String(), "bob@example.org")
+}
+
 func TestEditConflictResponse(t *testing.T) {
 	rr := httptest.NewRecorder()
 	app := application{}
@@ -157,6 +172,44 @@ func TestFailedValidationRequest(t *testing.T) {
 	)
 }
 
+func TestPreconditionFailedResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	app := application{}
+	req := httptest.NewRequest(
+		http.MethodPatch,
+		"/test/url",
+		nil,
+	)
+
+	app.preconditionFailedResponse(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Result().StatusCode)
+	assert.Equal(
+		t,
+		`{"error":"the resource has been modified since it was last retrieved, please fetch it again"}`,
+		strings.TrimSpace(rr.Body.String()),
+	)
+}
+
+func TestPreconditionRequiredResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	app := application{}
+	req := httptest.NewRequest(
+		http.MethodPatch,
+		"/test/url",
+		nil,
+	)
+
+	app.preconditionRequiredResponse(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Result().StatusCode)
+	assert.Equal(
+		t,
+		`{"error":"this request must be conditional, please provide an If-Match header"}`,
+		strings.TrimSpace(rr.Body.String()),
+	)
+}
+
 func TestRateLimitExceededResponse(t *testing.T) {
 	rr := httptest.NewRecorder()
 	app := application{}
@@ -175,3 +228,91 @@ func TestRateLimitExceededResponse(t *testing.T) {
 		strings.TrimSpace(rr.Body.String()),
 	)
 }
+
+func TestProblemResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		respond  func(app *application, w http.ResponseWriter, r *http.Request)
+		status   int
+		expected string
+	}{
+		{
+			"Not found",
+			func(app *application, w http.ResponseWriter, r *http.Request) { app.notFoundResponse(w, r) },
+			http.StatusNotFound,
+			`{"type":"urn:go-commerce:problem:not-found","title":"Resource not found","status":404,"detail":"the requested resource could not be found","instance":"checkout-42"}`,
+		},
+		{
+			"Failed validation",
+			func(app *application, w http.ResponseWriter, r *http.Request) {
+				app.failedValidationResponse(w, r, map[string]string{"name": "too short", "email": "duplicates"})
+			},
+			http.StatusUnprocessableEntity,
+			`{"type":"urn:go-commerce:problem:validation-failed","title":"Validation failed","status":422,"detail":"one or more fields are invalid","instance":"checkout-42","errors":[{"field":"email","detail":"duplicates"},{"field":"name","detail":"too short"}]}`,
+		},
+		{
+			"Rate limited",
+			func(app *application, w http.ResponseWriter, r *http.Request) { app.rateLimitExceededResponse(w, r) },
+			http.StatusTooManyRequests,
+			`{"type":"urn:go-commerce:problem:rate-limited","title":"Rate limit exceeded","status":429,"detail":"rate limit exceeded","instance":"checkout-42"}`,
+		},
+		{
+			"Untyped error",
+			func(app *application, w http.ResponseWriter, r *http.Request) {
+				app.errorResponse(w, r, http.StatusTeapot, "short and stout")
+			},
+			http.StatusTeapot,
+			`{"type":"about:blank","title":"I'm a teapot","status":418,"detail":"short and stout","instance":"checkout-42"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			app := &application{}
+			req := httptest.NewRequest(http.MethodGet, "/test/url", nil)
+			req.Header.Set("Accept", "application/json, application/problem+json")
+			req = req.WithContext(requestid.NewContext(req.Context(), "checkout-42"))
+
+			tc.respond(app, rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+			assert.Equal(t, tc.expected, strings.TrimSpace(rr.Body.String()))
+		})
+	}
+}
+
+func TestWantsProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		accept   string
+		expected bool
+	}{
+		{"Legacy by default", "legacy", "", false},
+		{"Legacy for plain JSON", "legacy", "application/json", false},
+		{"Negotiated", "legacy", "application/problem+json", true},
+		{"Negotiated with parameters", "legacy", "application/json;q=0.9, application/problem+json;q=1", true},
+		{"Refused with q=0", "legacy", "application/problem+json;q=0", false},
+		{"Refused with q=0.0", "legacy", "application/problem+json;q=0.0", false},
+		{"Refused with q=0.000", "legacy", "application/problem+json; q=0.000", false},
+		{"Negotiated with q=0.001", "legacy", "application/problem+json;q=0.001", true},
+		{"Forced by configuration", "problem", "application/json", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{}
+			app.config.errorsFormat = tc.format
+
+			req := httptest.NewRequest(http.MethodGet, "/test/url", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			assert.Equal(t, tc.expected, app.wantsProblem(req))
+		})
+	}
+}
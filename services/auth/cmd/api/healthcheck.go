@@ -1,12 +1,31 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+
+	"github.com/betasve/go-commerce/services/auth/internal/health"
 )
 
+// livenessHandler only tells the orchestrator that the process is serving
+// requests. It must not depend on anything else, or a database outage would
+// get every replica restarted.
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeLiveness(w, r, "alive")
+}
+
+// healthcheckHandler serves the probe from before liveness and readiness
+// were split, with the body monitors were written against.
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeLiveness(w, r, "available")
+}
+
+func (app *application) writeLiveness(w http.ResponseWriter, r *http.Request, status string) {
 	env := envelope{
-		"status": "available",
+		"status": status,
 		"system_info": map[string]string{
 			"environment": app.config.env,
 			"version":     version,
@@ -18,3 +37,54 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readinessHandler reports whether the dependencies are usable, and fails
+// while the server is draining before shutdown. The errors behind a failed
+// check are only shown on the admin server, by healthDetailsHandler.
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeReadiness(w, r, app.health.Check(r.Context()).WithoutErrors())
+}
+
+// healthDetailsHandler is readinessHandler with the error of each failed
+// check.
+func (app *application) healthDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeReadiness(w, r, app.health.Check(r.Context()))
+}
+
+func (app *application) writeReadiness(w http.ResponseWriter, r *http.Request, report health.Report) {
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+
+	err := app.writeJSON(w, status, envelope{"health": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// schemaCheck fails while the database is behind the migrations compiled
+// into the binary, or a migration was left half applied.
+func schemaCheck(db *sql.DB, latest uint) health.CheckFunc {
+	return func(ctx context.Context) error {
+		var (
+			version uint
+			dirty   bool
+		)
+
+		err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("no migrations applied, expected version %d", latest)
+		case err != nil:
+			return err
+		case dirty:
+			return fmt.Errorf("schema is dirty at version %d", version)
+		case version < latest:
+			return fmt.Errorf("schema at version %d, %d pending", version, latest-version)
+		}
+
+		return nil
+	}
+}
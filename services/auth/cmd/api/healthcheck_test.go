@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/betasve/go-commerce/services/auth/internal/health"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestHealthcheckHandler(t *testing.T) {
+func TestLivenessHandler(t *testing.T) {
 	rr := httptest.NewRecorder()
 	app := application{
 		config: config{
@@ -18,11 +23,29 @@ func TestHealthcheckHandler(t *testing.T) {
 	}
 	req := httptest.NewRequest(
 		http.MethodGet,
-		"/test/url",
+		"/v1/health/live",
 		nil,
 	)
 
-	app.healthcheckHandler(rr, req)
+	app.livenessHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	assert.Equal(
+		t,
+		`{"status":"alive","system_info":{"environment":"test","version":"1.0.0"}}`,
+		strings.TrimSpace(rr.Body.String()),
+	)
+}
+
+func TestHealthcheckHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	app := application{
+		config: config{
+			env: "test",
+		},
+	}
+
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
 
 	assert.Equal(t, http.StatusOK, rr.Result().StatusCode)
 	assert.Equal(
@@ -31,3 +54,46 @@ func TestHealthcheckHandler(t *testing.T) {
 		strings.TrimSpace(rr.Body.String()),
 	)
 }
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		dbErr          error
+		drain          bool
+		expectedCode   int
+		expectedStatus string
+		expectedDB     string
+	}{
+		{"Ready", nil, false, http.StatusOK, health.StatusReady, health.StatusUp},
+		{"Database down", errors.New("connection refused"), false, http.StatusServiceUnavailable, health.StatusNotReady, health.StatusDown},
+		{"Draining", nil, true, http.StatusServiceUnavailable, health.StatusDraining, health.StatusUp},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := application{health: health.NewChecker(time.Second)}
+			app.health.Register("database", time.Second, func(context.Context) error { return tc.dbErr })
+
+			if tc.drain {
+				app.health.Drain()
+			}
+
+			rr := httptest.NewRecorder()
+			app.readinessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+			var body struct {
+				Health health.Report `json:"health"`
+			}
+
+			err := json.NewDecoder(rr.Body).Decode(&body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			assert.Equal(t, tc.expectedStatus, body.Health.Status)
+			assert.Equal(t, tc.expectedDB, body.Health.Components["database"].Status)
+			assert.Empty(t, body.Health.Components["database"].Error)
+		})
+	}
+}
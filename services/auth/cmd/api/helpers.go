@@ -5,17 +5,85 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
 	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/betasve/go-commerce/services/auth/pkg/tracing"
 	"github.com/julienschmidt/httprouter"
 )
 
 type envelope map[string]interface{}
 
+// logProperties adds the identifiers that tie a log entry to its request and
+// trace to properties.
+func (app *application) logProperties(r *http.Request, properties map[string]string) map[string]string {
+	if id := requestid.FromContext(r.Context()); id != "" {
+		properties["request_id"] = id
+	}
+
+	if traceID, spanID := tracing.IDs(r.Context()); traceID != "" {
+		properties["trace_id"] = traceID
+		properties["span_id"] = spanID
+	}
+
+	return properties
+}
+
+// clientIP returns the address of the caller. X-Forwarded-For is only
+// believed when the request came through a trusted proxy, and is read from
+// the right, so that a client cannot pick its own address by sending the
+// header itself.
+func (app *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !app.trustedProxy(ip) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		ip = hop.Unmap()
+		if !app.trustedProxy(ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+func (app *application) trustedProxy(ip netip.Addr) bool {
+	for _, prefix := range app.config.limiter.trustedProxies {
+		if prefix.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ceilSeconds formats d as whole seconds, rounded up, as the Retry-After and
+// RateLimit-Reset headers expect.
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
 func (app *application) readIDParam(r *http.Request) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
@@ -28,6 +96,31 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// versionETag renders a record version as a strong entity tag.
+func versionETag(version int32) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch reports whether the request carries an If-Match header and, if so,
+// whether any of its entity tags matches etag. Tags of compressed responses
+// match the version they were made from.
+func (app *application) ifMatch(r *http.Request, etag string) (present bool, matches bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return false, false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" || decodedETag(tag) == etag {
+			return true, true
+		}
+	}
+
+	return true, false
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	js, err := json.Marshal(data)
 
@@ -41,6 +134,10 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
+	if status == http.StatusOK && w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", weakETag(js))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	w.Write(js)
@@ -48,9 +145,11 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	return nil
 }
 
+// maxBodyBytes caps the size of JSON request bodies.
+const maxBodyBytes = 1_048_576
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -78,7 +177,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
 			return fmt.Errorf("the body contains unknown key %s", fieldName)
 		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+			return fmt.Errorf("body must not be larger than %d bytes", maxBodyBytes)
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
 		default:
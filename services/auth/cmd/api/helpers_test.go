@@ -61,6 +61,44 @@ func TestReadIDParam(t *testing.T) {
 	}
 }
 
+func TestVersionETag(t *testing.T) {
+	assert.Equal(t, `"7"`, versionETag(7))
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		expectedPresent bool
+		expectedMatches bool
+	}{
+		{"Missing header", "", false, false},
+		{"Matching tag", `"3"`, true, true},
+		{"Stale tag", `"2"`, true, false},
+		{"Tag in a list", `"1", "3"`, true, true},
+		{"Weak tags never match", `W/"3"`, true, false},
+		{"Tag of a gzip response", `"3-gzip"`, true, true},
+		{"Tag of a brotli response", `"3-br"`, true, true},
+		{"Weak tag of a gzip response", `W/"3-gzip"`, true, false},
+		{"Wildcard", "*", true, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/test/url", nil)
+			if tc.header != "" {
+				req.Header.Set("If-Match", tc.header)
+			}
+
+			app := application{}
+			present, matches := app.ifMatch(req, `"3"`)
+
+			assert.Equal(t, tc.expectedPresent, present)
+			assert.Equal(t, tc.expectedMatches, matches)
+		})
+	}
+}
+
 func TestWriteJSON(t *testing.T) {
 	user := testStruct{
 		Email: "test@example.com",
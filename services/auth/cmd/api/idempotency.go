@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyStoredHeaders are the response headers replayed to retries.
+// Everything else, such as the request ID and rate limit headers, belongs to
+// the retry itself.
+var idempotencyStoredHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+// validIdempotencyKey accepts up to 255 printable ASCII characters, which
+// covers the UUIDs clients are expected to send.
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > 255 {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// idempotencyFingerprint identifies what a request asks for, so that a key
+// reused for another request can be told apart from a retry.
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+
+	io.WriteString(h, r.Method+"\n"+r.URL.RequestURI()+"\n"+r.Header.Get("If-Match")+"\n")
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotent makes POST and PATCH requests carrying an Idempotency-Key safe
+// to retry: the first one runs and its response is stored, and retries get
+// that response back instead of running again. Server errors are not stored,
+// so a retry after one runs again.
+//
+// Keys are scoped to the route and the user. Anonymous callers share a
+// scope, since their IP address changes as mobile networks do; a different
+// payload under a known key is rejected, so sharing cannot leak responses
+// to other requests.
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) || app.models.Idempotency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			app.badRequestResponse(w, r, errors.New("the Idempotency-Key header must be 1 to 255 printable ASCII characters"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		info := app.contextGetRequestInfo(r)
+
+		// Requests are not authenticated yet, so every caller shares the
+		// anonymous scope. Keys get a principal of their own with
+		// authentication.
+		req := &data.IdempotentRequest{
+			Scope:       "anonymous " + r.Method + " " + info.routeOrUnmatched(),
+			Key:         key,
+			Fingerprint: idempotencyFingerprint(r, body),
+		}
+
+		existing, err := app.models.Idempotency.Claim(r.Context(), req, app.config.idempotency.lockTimeout, app.config.idempotency.ttl)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyInUse):
+				app.idempotencyKeyInUseResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != req.Fingerprint:
+				app.idempotencyKeyReusedResponse(w, r)
+			case existing.InFlight():
+				app.idempotencyKeyInUseResponse(w, r)
+			default:
+				replayIdempotentResponse(w, existing)
+			}
+			return
+		}
+
+		// The response may already be on its way to a client that has gone,
+		// so storing it must not depend on the request's context.
+		ctx := context.WithoutCancel(r.Context())
+
+		completed := false
+
+		// Deferred so that a panic in the handler releases the key too.
+		defer func() {
+			if completed {
+				return
+			}
+
+			err := app.models.Idempotency.Release(ctx, req)
+			if err != nil {
+				app.logError(r, err)
+			}
+		}()
+
+		rec := &idempotentResponseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		req.Status = rec.status
+		req.Header = rec.header
+		req.Body = rec.body.Bytes()
+
+		err = app.models.Idempotency.Complete(ctx, req)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+
+		completed = true
+	})
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, stored *data.IdempotentRequest) {
+	for key, values := range stored.Header {
+		w.Header()[key] = values
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// idempotentResponseWriter passes the response through and keeps a copy of
+// it to store.
+type idempotentResponseWriter struct {
+	http.ResponseWriter
+	status int
+	header map[string][]string
+	body   bytes.Buffer
+}
+
+func (w *idempotentResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = make(map[string][]string)
+
+		for _, key := range idempotencyStoredHeaders {
+			if values := w.Header().Values(key); len(values) > 0 {
+				w.header[key] = append([]string(nil), values...)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotentResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// expireIdempotencyKeys deletes expired idempotency keys every interval
+// until ctx is done. Claim reuses expired keys on its own; this keeps the
+// table from growing.
+func (app *application) expireIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := app.models.Idempotency.DeleteExpired(ctx)
+		if err != nil {
+			// A delete cut short by shutdown is not worth reporting.
+			if ctx.Err() == nil {
+				app.logger.PrintError(err, nil)
+			}
+			continue
+		}
+
+		if deleted > 0 {
+			app.logger.PrintInfo("expired idempotency keys deleted", map[string]string{
+				"deleted": strconv.FormatInt(deleted, 10),
+			})
+		}
+	}
+}
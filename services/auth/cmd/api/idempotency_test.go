@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/background"
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotencyTestApp(t *testing.T) *application {
+	models := newTestModels(t, true)
+	models.Idempotency = data.NewMemoryIdempotencyModel()
+
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models: models,
+	}
+	app.config.idempotency.ttl = time.Hour
+	app.config.idempotency.lockTimeout = time.Minute
+	app.config.contract.strict = true
+
+	return app
+}
+
+func TestIdempotentCreateUser(t *testing.T) {
+	app := newIdempotencyTestApp(t)
+	handler := app.routes()
+
+	do := func(method, url, key, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	body := `{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`
+
+	first := do(http.MethodPost, "/v1/users", "key-1", body)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := do(http.MethodPost, "/v1/users", "key-1", body)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/v1/users/2", retry.Header().Get("Location"))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.NotEqual(t, first.Header().Get("X-Request-ID"), retry.Header().Get("X-Request-ID"))
+
+	reused := do(http.MethodPost, "/v1/users", "key-1", `{"name":"John Smith","email":"john@example.com","password":"Password123"}`)
+	assert.Equal(t, http.StatusConflict, reused.Code)
+	assert.JSONEq(t, `{"error":"this Idempotency-Key was already used for a different request","request_id":"`+reused.Header().Get("X-Request-ID")+`"}`, reused.Body.String())
+
+	// Without a key, the same request runs again and fails on the email.
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/v1/users", "", body).Code)
+
+	list := do(http.MethodGet, "/v1/users", "key-1", "")
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Contains(t, list.Body.String(), `"total_records":2`)
+
+	invalid := do(http.MethodPost, "/v1/users", strings.Repeat("k", 256), body)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
+func TestIdempotentInFlight(t *testing.T) {
+	app := newIdempotencyTestApp(t)
+
+	body := `{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`
+
+	_, err := app.models.Idempotency.Claim(context.Background(), &data.IdempotentRequest{
+		Scope:       "anonymous POST /v1/users",
+		Key:         "key-1",
+		Fingerprint: idempotencyFingerprint(httptest.NewRequest(http.MethodPost, "/v1/users", nil), []byte(body)),
+	}, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	req.Header.Set("Accept", problemContentType)
+
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), `"type":"urn:go-commerce:problem:idempotency-key-in-use"`)
+}
+
+func TestIdempotentTakesOverStaleClaim(t *testing.T) {
+	app := newIdempotencyTestApp(t)
+
+	body := `{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`
+
+	// The request that claimed the key died before its lock ran out.
+	_, err := app.models.Idempotency.Claim(context.Background(), &data.IdempotentRequest{
+		Scope:       "anonymous POST /v1/users",
+		Key:         "key-1",
+		Fingerprint: idempotencyFingerprint(httptest.NewRequest(http.MethodPost, "/v1/users", nil), []byte(body)),
+	}, time.Millisecond, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get("Idempotent-Replayed"))
+}
+
+// busyIdempotencyStore never manages to claim a key.
+type busyIdempotencyStore struct {
+	data.IdempotencyStore
+}
+
+func (busyIdempotencyStore) Claim(context.Context, *data.IdempotentRequest, time.Duration, time.Duration) (*data.IdempotentRequest, error) {
+	return nil, data.ErrIdempotencyKeyInUse
+}
+
+func TestIdempotentClaimContended(t *testing.T) {
+	app := newIdempotencyTestApp(t)
+	app.models.Idempotency = busyIdempotencyStore{app.models.Idempotency}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}`))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	req.Header.Set("Accept", problemContentType)
+
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"type":"urn:go-commerce:problem:idempotency-key-in-use"`)
+}
+
+func TestIdempotentReleasesOnFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"Server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}},
+		{"Panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newIdempotencyTestApp(t)
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{}`))
+			req.Header.Set(idempotencyKeyHeader, "key-1")
+
+			app.recoverPanic(app.idempotent(tc.handler)).ServeHTTP(rr, req)
+
+			existing, err := app.models.Idempotency.Claim(context.Background(), &data.IdempotentRequest{
+				Scope:       "anonymous POST unmatched",
+				Key:         "key-1",
+				Fingerprint: "retry",
+			}, time.Minute, time.Hour)
+
+			assert.NoError(t, err)
+			assert.Nil(t, existing, "expected the key to be released")
+		})
+	}
+}
+
+func TestExpireIdempotencyKeysStopsOnShutdown(t *testing.T) {
+	logs := &bytes.Buffer{}
+
+	app := newIdempotencyTestApp(t)
+	app.logger = jsonlog.New(logs, jsonlog.LevelInfo)
+	app.tasks = background.New(app.logger)
+
+	err := app.tasks.Go("expire-idempotency-keys", func(ctx context.Context) {
+		app.expireIdempotencyKeys(ctx, time.Millisecond)
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, app.tasks.Shutdown(ctx))
+	assert.NotContains(t, logs.String(), "abandoned background task")
+}
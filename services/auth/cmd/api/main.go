@@ -5,71 +5,441 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/betasve/go-commerce/services/auth/internal/background"
+	appconfig "github.com/betasve/go-commerce/services/auth/internal/config"
 	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/health"
 	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/betasve/go-commerce/services/auth/internal/migrations"
+	"github.com/betasve/go-commerce/services/auth/internal/ratelimit"
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
+	"github.com/betasve/go-commerce/services/auth/internal/tlsconfig"
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/betasve/go-commerce/services/auth/pkg/metrics"
+	"github.com/betasve/go-commerce/services/auth/pkg/tracing"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
 )
 
 const version = "1.0.0"
 
 type config struct {
-	port int
-	env  string
-	db   struct {
-		dsn     string
-		migrate string
+	port         int
+	adminPort    int
+	adminHost    string
+	env          string
+	errorsFormat string
+	contract     struct {
+		strict bool
+	}
+	tls struct {
+		certFile       string
+		keyFile        string
+		clientCAFile   string
+		clientAuth     string
+		minVersion     string
+		reloadInterval time.Duration
+	}
+	db struct {
+		dsn    string
+		memory bool
+
+		autoMigrate    bool
+		migrateTimeout time.Duration
+
+		maxOpenConns int
+		maxIdleConns int
+		maxIdleTime  time.Duration
+
+		replicaDSNs          []string
+		replicaCheckInterval time.Duration
+		readYourWrites       bool
 	}
 	limiter struct {
-		rps     float64
-		burst   int
+		enabled        bool
+		backend        string
+		redisURL       string
+		defaultLimit   ratelimit.Limit
+		routes         map[string]ratelimit.Limit
+		trustedProxies []netip.Prefix
+	}
+	cors struct {
+		trustedOrigins   []string
+		allowCredentials bool
+		maxAge           time.Duration
+	}
+	idempotency struct {
+		ttl         time.Duration
+		lockTimeout time.Duration
+	}
+	compression struct {
 		enabled bool
+		minSize int
+	}
+	log struct {
+		level      jsonlog.Level
+		traceLevel jsonlog.Level
+		redactKeys []string
+		detectors  []string
+	}
+	accessLog struct {
+		enabled       bool
+		sampleRate    float64
+		slowThreshold time.Duration
+	}
+	shutdown struct {
+		timeout     time.Duration
+		taskTimeout time.Duration
+	}
+	health struct {
+		cacheTTL     time.Duration
+		checkTimeout time.Duration
+		drainDelay   time.Duration
+		kafkaBrokers []string
+		jwksURL      string
+	}
+	tracing struct {
+		exporter     string
+		otlpEndpoint string
+		otlpInsecure bool
+		sampleRatio  float64
 	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
+	config   config
+	settings []appconfig.Setting
+	logger   *jsonlog.Logger
+	models   data.Models
+	metrics  *metrics.Metrics
+	limiter  ratelimit.Limiter
+	health   *health.Checker
+	tasks    *background.Runner
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string, io.Writer) error{
+			"migrate": runMigrate,
+			"seed":    runSeed,
+		}
+
+		if run, ok := commands[os.Args[1]]; ok {
+			err := run(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+
+			return
+		}
+	}
+
 	var cfg config
 
+	flag.String("config", "", "YAML or TOML file with settings named like the flags; the environment and flags override it")
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
+	flag.IntVar(&cfg.adminPort, "admin-port", 4001, "Admin server port for debug endpoints (0 disables it)")
+	flag.StringVar(&cfg.adminHost, "admin-host", "127.0.0.1", "Address the admin server listens on; it has no authentication, so only widen it (e.g. to 0.0.0.0) on a trusted network")
 	flag.StringVar(&cfg.env, "env", "development", "development|staging|production")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GO_COMMERCE_DB_DSN"), "PostgreSQL DSN")
-	flag.StringVar(&cfg.db.migrate, "db-migrate", "false", "Trigger DB Migration")
+	flag.BoolVar(&cfg.contract.strict, "contract-strict", false, "Replace responses that do not match the OpenAPI spec with a 500 and log the difference")
+	flag.StringVar(&cfg.errorsFormat, "errors-format", "legacy", `Error body format: legacy ({"error": ...}, problem+json on request via Accept) or problem (always problem+json)`)
+	flag.StringVar(&cfg.tls.certFile, "tls-cert-file", "", "PEM certificate chain to serve the API over HTTPS with (requires -tls-key-file)")
+	flag.StringVar(&cfg.tls.keyFile, "tls-key-file", "", "PEM private key of -tls-cert-file")
+	flag.StringVar(&cfg.tls.clientCAFile, "tls-client-ca-file", "", "PEM certificates that client certificates must chain to")
+	flag.StringVar(&cfg.tls.clientAuth, "tls-client-auth", tlsconfig.ClientAuthNone, "Client certificate verification: "+strings.Join(tlsconfig.ClientAuthModes, "|"))
+	flag.StringVar(&cfg.tls.minVersion, "tls-min-version", "1.2", "Minimum TLS version: "+strings.Join(tlsconfig.MinVersions, "|"))
+	flag.DurationVar(&cfg.tls.reloadInterval, "tls-reload-interval", 30*time.Second, "How often to check the TLS files for changes (0 disables it; SIGHUP always reloads)")
+	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
+	flag.BoolVar(&cfg.db.autoMigrate, "db-auto-migrate", false, "Apply pending migrations on startup")
+	flag.DurationVar(&cfg.db.migrateTimeout, "db-migrate-timeout", time.Minute, "Maximum time to wait for the migration lock and run startup migrations; a migration still running then is cancelled")
+	flag.BoolVar(&cfg.db.memory, "db-memory", false, "Use an in-memory store instead of PostgreSQL (development only)")
+
+	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections per pool")
+	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections per pool")
+	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15*time.Minute, "PostgreSQL max connection idle time")
 
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	flag.Func("db-replica-dsns", "Comma-separated PostgreSQL read replica DSNs", func(val string) error {
+		cfg.db.replicaDSNs = append(cfg.db.replicaDSNs, strings.Split(val, ",")...)
+		return nil
+	})
+	flag.DurationVar(&cfg.db.replicaCheckInterval, "db-replica-check-interval", 10*time.Second, "Read replica health check interval")
+	flag.BoolVar(&cfg.db.readYourWrites, "db-read-your-writes", true, "Serve reads from the primary after a write in the same request")
+
+	// Requests are not authenticated yet, so every limit applies per client
+	// IP. Per-user limits come with authentication.
+	flag.Float64Var(&cfg.limiter.defaultLimit.Rate, "limiter-rps", 2, "Rate limiter maximum requests per second per client IP")
+	flag.IntVar(&cfg.limiter.defaultLimit.Burst, "limiter-burst", 4, "Rate limiter maximum burst per client IP")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.StringVar(&cfg.limiter.backend, "limiter-backend", "memory", "Rate limiter store: memory (per replica) or redis (shared)")
+	flag.StringVar(&cfg.limiter.redisURL, "limiter-redis-url", "", "Redis URL for the redis rate limiter backend")
+
+	// Creating users hashes a password, so it gets a tighter limit than
+	// everything else, and health checks from load balancers get none.
+	cfg.limiter.routes = map[string]ratelimit.Limit{
+		"GET /v1/healthcheck":  {},
+		"GET /v1/health/live":  {},
+		"GET /v1/health/ready": {},
+		"POST /v1/users":       {Rate: 0.1, Burst: 3},
+	}
+
+	flag.Func("limiter-route", `Per-route limit per client IP "METHOD /pattern=RATE:BURST", 0:0 disables it (repeatable)`, func(val string) error {
+		route, limit, err := ratelimit.ParseRouteLimit(val)
+		if err != nil {
+			return err
+		}
+
+		cfg.limiter.routes[route] = limit
+		return nil
+	})
+	flag.Func("limiter-trusted-proxies", "Comma-separated CIDRs of proxies whose X-Forwarded-For is trusted", func(val string) error {
+		for _, cidr := range strings.Split(val, ",") {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+			if err != nil {
+				return err
+			}
 
-	flag.Parse()
+			cfg.limiter.trustedProxies = append(cfg.limiter.trustedProxies, prefix)
+		}
 
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+		return nil
+	})
 
-	db, err := openDB(cfg)
+	flag.Func("cors-trusted-origins", "Comma-separated origins allowed to call the API from a browser, e.g. https://shop.example.com or https://*.example.com", func(val string) error {
+		for _, origin := range strings.Split(val, ",") {
+			origin, err := parseOrigin(strings.TrimSpace(origin))
+			if err != nil {
+				return err
+			}
+
+			cfg.cors.trustedOrigins = append(cfg.cors.trustedOrigins, origin)
+		}
+
+		return nil
+	})
+	flag.BoolVar(&cfg.cors.allowCredentials, "cors-allow-credentials", false, "Let trusted origins send cookies and Authorization headers")
+	flag.DurationVar(&cfg.cors.maxAge, "cors-max-age", 10*time.Minute, "How long browsers may cache a preflight response (0 leaves it to the browser)")
+
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "How long responses to requests with an Idempotency-Key are kept for retries")
+	flag.DurationVar(&cfg.idempotency.lockTimeout, "idempotency-lock-timeout", 2*time.Minute, "How long a request with an Idempotency-Key may be in flight before a retry takes the key over")
+
+	flag.BoolVar(&cfg.compression.enabled, "compress", true, "Compress responses with brotli or gzip when the client accepts it")
+	flag.IntVar(&cfg.compression.minSize, "compress-min-size", 1024, "Smallest response body in bytes worth compressing")
+
+	flag.BoolVar(&cfg.accessLog.enabled, "access-log", true, "Log every HTTP request")
+	flag.Float64Var(&cfg.accessLog.sampleRate, "access-log-sample-rate", 1, "Fraction of 2xx requests to log (other statuses are always logged)")
+	flag.DurationVar(&cfg.accessLog.slowThreshold, "access-log-slow-threshold", 500*time.Millisecond, "Always log requests slower than this (0 disables)")
+	flag.DurationVar(&cfg.shutdown.timeout, "shutdown-timeout", 9*time.Second, "Maximum time shutdown takes in all, drain delay and background tasks included; keep it below the stop grace period (10s in docker)")
+	flag.DurationVar(&cfg.shutdown.taskTimeout, "shutdown-task-timeout", 3*time.Second, "Part of -shutdown-timeout kept for background tasks, after requests")
+
+	flag.DurationVar(&cfg.health.cacheTTL, "health-cache-ttl", 2*time.Second, "How long readiness check results are reused")
+	flag.DurationVar(&cfg.health.checkTimeout, "health-check-timeout", time.Second, "Timeout of each readiness check")
+	flag.DurationVar(&cfg.health.drainDelay, "health-drain-delay", 3*time.Second, "How long readiness fails before the server stops accepting requests on shutdown")
+	flag.Func("kafka-brokers", "Comma-separated Kafka brokers checked for readiness", func(val string) error {
+		cfg.health.kafkaBrokers = append(cfg.health.kafkaBrokers, strings.Split(val, ",")...)
+		return nil
+	})
+	flag.StringVar(&cfg.health.jwksURL, "health-jwks-url", "", "JWKS URL checked for readiness, if the service depends on one")
+
+	flag.StringVar(&cfg.tracing.exporter, "trace-exporter", "none", "Trace exporter: "+strings.Join(tracing.Exporters, "|")+" (stdout writes spans to stderr, apart from the log)")
+	flag.StringVar(&cfg.tracing.otlpEndpoint, "trace-otlp-endpoint", "", "OTLP/HTTP collector host:port (defaults to OTEL_EXPORTER_OTLP_* settings)")
+	flag.BoolVar(&cfg.tracing.otlpInsecure, "trace-otlp-insecure", false, "Send spans to -trace-otlp-endpoint over plain HTTP instead of HTTPS")
+	flag.Float64Var(&cfg.tracing.sampleRatio, "trace-sample-ratio", 1, "Fraction of new traces to sample (incoming sampling decisions are kept)")
+
+	flag.TextVar(&cfg.log.level, "log-level", jsonlog.LevelInfo, "Minimum log level: debug|info|warn|error|fatal|off (adjustable at runtime on the admin server)")
+	flag.TextVar(&cfg.log.traceLevel, "log-trace-level", jsonlog.LevelError, "Attach stack traces to log entries at this level or above (off disables them)")
+	flag.Func("log-redact-keys", "Comma-separated regular expressions for further property and query parameter names to redact in logs", func(val string) error {
+		// Blank entries, e.g. from a trailing comma, are skipped rather than
+		// redacting every key.
+		for _, key := range strings.Split(val, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				cfg.log.redactKeys = append(cfg.log.redactKeys, key)
+			}
+		}
+		return nil
+	})
+	detectors := make([]string, len(jsonlog.Detectors))
+	for i, d := range jsonlog.Detectors {
+		detectors[i] = d.Name
+	}
+	flag.Func("log-redact-detectors", "Comma-separated detectors of sensitive values in logs, or none (default "+strings.Join(detectors, ",")+")", func(val string) error {
+		cfg.log.detectors = strings.Split(val, ",")
+		if val == "none" {
+			cfg.log.detectors = []string{}
+		}
+		return nil
+	})
+
+	settings, err := appconfig.Load(flag.CommandLine, os.Args[1:], configOptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	v := validator.New()
+
+	if validateConfig(v, cfg); !v.Valid() {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", configErrors(v.Errors))
+		os.Exit(2)
+	}
+
+	logger := jsonlog.New(os.Stdout, cfg.log.level)
+	logger.SetTraceLevel(cfg.log.traceLevel)
+
+	redactor, err := logRedactor(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
+	logger.SetRedactor(redactor)
 
-	defer db.Close()
+	// Libraries logging through log/slog, or the log package, end up in
+	// the same stream, as do errors the OTel SDK cannot return to a caller,
+	// such as failed span exports.
+	slog.SetDefault(slog.New(logger.Handler()))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Error(err.Error(), "component", "otel")
+	}))
 
-	logger.PrintInfo("database connection pool established", nil)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Service:     "auth",
+		Version:     version,
+		Exporter:    cfg.tracing.exporter,
+		Endpoint:    cfg.tracing.otlpEndpoint,
+		Insecure:    cfg.tracing.otlpInsecure,
+		SampleRatio: cfg.tracing.sampleRatio,
+	})
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := shutdownTracing(ctx)
+		if err != nil {
+			logger.PrintError(err, nil)
+		}
+	}()
 
 	app := application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:   cfg,
+		settings: settings,
+		logger:   logger,
+		metrics:  metrics.New("auth"),
+		health:   health.NewChecker(cfg.health.cacheTTL),
+		tasks:    background.New(logger),
+	}
+
+	if cfg.db.memory {
+		app.models = data.NewMemoryModels()
+
+		logger.PrintInfo("using in-memory data store", nil)
+	} else {
+		db, err := openDB(cfg)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		defer db.Close()
+
+		healthy, total := db.Healthy()
+		logger.PrintInfo("database connection pool established", map[string]string{
+			"replicas":         strconv.Itoa(total),
+			"healthy_replicas": strconv.Itoa(healthy),
+		})
+
+		app.models = data.NewModels(db)
+
+		if total > 0 {
+			err = app.tasks.Go("check-db-replicas", func(ctx context.Context) {
+				db.CheckReplicas(ctx, cfg.db.replicaCheckInterval)
+			})
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+		}
+
+		embedded, err := migrations.Load(migrationFiles)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		app.health.Register("database", cfg.health.checkTimeout, db.Primary().PingContext)
+		app.health.Register("migrations", cfg.health.checkTimeout, schemaCheck(db.Primary(), embedded.Latest()))
+
+		publishDBStats(db)
+
+		err = app.metrics.RegisterDBStats(db.Stats)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		if cfg.db.autoMigrate {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.db.migrateTimeout)
+			from, to, err := autoMigrate(ctx, db.Primary())
+			cancel()
+
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+
+			logger.PrintInfo("database migrations applied", map[string]string{
+				"from_version": strconv.FormatUint(uint64(from), 10),
+				"to_version":   strconv.FormatUint(uint64(to), 10),
+			})
+		}
+	}
+
+	app.limiter, err = openLimiter(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	for _, broker := range cfg.health.kafkaBrokers {
+		app.health.Register("kafka:"+broker, cfg.health.checkTimeout, health.TCP(broker))
+	}
+
+	if cfg.health.jwksURL != "" {
+		client := &http.Client{Transport: tracing.Transport(&requestid.Transport{})}
+		app.health.Register("jwks", cfg.health.checkTimeout, health.HTTP(client, cfg.health.jwksURL))
+	}
+
+	logger.PrintInfo("readiness checks registered", map[string]string{
+		"checks": strings.Join(app.health.Names(), ","),
+	})
+
+	logger.PrintInfo("rate limiter configured", map[string]string{
+		"enabled": strconv.FormatBool(cfg.limiter.enabled),
+		"backend": cfg.limiter.backend,
+	})
+
+	dbSystem := "postgresql"
+	if cfg.db.memory {
+		dbSystem = "memory"
 	}
 
-	app.migrateDB(db)
+	app.models = app.models.Instrument(
+		data.Trace(otel.Tracer("github.com/betasve/go-commerce/services/auth/internal/data"), dbSystem),
+		data.Observe(app.metrics.ObserveQuery),
+	)
+
+	err = app.tasks.Go("expire-idempotency-keys", func(ctx context.Context) {
+		app.expireIdempotencyKeys(ctx, time.Hour)
+	})
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 
 	err = app.serve()
 	if err != nil {
@@ -77,8 +447,35 @@ func main() {
 	}
 }
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
+func openLimiter(cfg config) (ratelimit.Limiter, error) {
+	switch cfg.limiter.backend {
+	case "memory":
+		return ratelimit.NewMemory(), nil
+	case "redis":
+		opts, err := redis.ParseURL(cfg.limiter.redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("limiter redis url: %w", err)
+		}
+
+		client := redis.NewClient(opts)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err = client.Ping(ctx).Err()
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("limiter redis: %w", err)
+		}
+
+		return &ratelimit.Redis{Client: client, Prefix: "gc-auth:ratelimit:"}, nil
+	default:
+		return nil, fmt.Errorf("unknown limiter backend %q", cfg.limiter.backend)
+	}
+}
+
+func openDB(cfg config) (*data.Router, error) {
+	db, err := openPool(cfg, cfg.db.dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -89,34 +486,39 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	err = db.PingContext(ctx)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return db, nil
-}
+	// Replicas are not pinged here: one being down must not stop the service
+	// from starting, the router simply keeps reads on the primary.
+	var replicas []*sql.DB
 
-func migrateDB(db *sql.DB) error {
-	migrationDriver, err := postgres.WithInstance(db, &postgres.Config{})
+	for _, dsn := range cfg.db.replicaDSNs {
+		replica, err := openPool(cfg, strings.TrimSpace(dsn))
+		if err != nil {
+			db.Close()
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			return nil, err
+		}
 
-	if err != nil {
-		return err
+		replicas = append(replicas, replica)
 	}
 
-	migrator, err := migrate.NewWithDatabaseInstance(
-		fmt.Sprintf("file:///%s/db/migrations/", os.Getenv("SERVICE_ROOT_PATH")),
-		"postgres",
-		migrationDriver,
-	)
+	return data.NewRouter(db, replicas), nil
+}
 
+func openPool(cfg config, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = migrator.Up()
+	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	db.SetConnMaxIdleTime(cfg.db.maxIdleTime)
 
-	if err != nil && err != migrate.ErrNoChange {
-		return err
-	}
-
-	return nil
+	return db, nil
 }
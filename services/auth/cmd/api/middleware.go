@@ -2,18 +2,38 @@ package main
 
 import (
 	"fmt"
-	"net"
+	"math/rand/v2"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// requestID keeps the caller's X-Request-ID, or generates one when it is
+// missing or malformed, and echoes it back in the response.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				app.metrics.PanicRecovered()
+
 				w.Header().Set("Connection", "close")
 				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
 			}
@@ -23,59 +43,160 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) rateLimit(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
+// rateLimit applies the limit of route, or the default one when the route
+// has none, per client IP. Routes without a limit of their own share one
+// bucket per client. The limiter failing must not take the API down, so
+// errors let requests through. Without a limiter, nothing is limited.
+func (app *application) rateLimit(route string, next http.Handler) http.Handler {
+	limit, bucket := app.config.limiter.defaultLimit, "default"
+	if l, ok := app.config.limiter.routes[route]; ok {
+		limit, bucket = l, route
 	}
 
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled || app.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			mu.Lock()
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
+		result, err := app.limiter.Allow(r.Context(), bucket+"|ip:"+app.clientIP(r), limit)
+		if err != nil {
+			app.logError(r, fmt.Errorf("rate limiter: %w", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(result.ResetAfter))
 
-			mu.Unlock()
+		if !result.Allowed {
+			app.metrics.RateLimited()
+
+			w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+			app.rateLimitExceededResponse(w, r)
+			return
 		}
-	}()
 
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) readYourWrites(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+		if app.config.db.readYourWrites {
+			r = r.WithContext(data.WithReadYourWrites(r.Context()))
+		}
 
-			mu.Lock()
+		next.ServeHTTP(w, r)
+	})
+}
 
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
-			}
+// routePattern records the pattern a handler was registered under, so that
+// the access log can group /v1/users/1 and /v1/users/2 together.
+func (app *application) routePattern(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.contextGetRequestInfo(r).route = pattern
 
-			clients[ip].lastSeen = time.Now()
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + pattern)
+		span.SetAttributes(semconv.HTTPRoute(pattern))
 
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
-			}
+		next.ServeHTTP(w, r)
+	})
+}
 
-			mu.Unlock()
+// instrument records the count and latency of every request by route.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mw := &metricsResponseWriter{wrapped: w, statusCode: http.StatusOK}
+
+		r, info := app.contextSetRequestInfo(r)
+
+		next.ServeHTTP(mw, r)
+
+		app.metrics.ObserveRequest(info.routeOrUnmatched(), r.Method, mw.statusCode, time.Since(start))
+	})
+}
+
+// accessLog writes one line per request. Successful responses are sampled,
+// everything else, and anything slower than the threshold, is always logged.
+func (app *application) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.accessLog.enabled {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		mw := &metricsResponseWriter{wrapped: w, statusCode: http.StatusOK}
+
+		r, info := app.contextSetRequestInfo(r)
+
+		next.ServeHTTP(mw, r)
+
+		duration := time.Since(start)
+		slow := app.config.accessLog.slowThreshold > 0 && duration >= app.config.accessLog.slowThreshold
+
+		if mw.statusCode < 300 && !slow && rand.Float64() >= app.config.accessLog.sampleRate {
+			return
+		}
+
+		properties := app.logProperties(r, map[string]string{
+			"request_method": r.Method,
+			"route":          info.routeOrUnmatched(),
+			"status":         strconv.Itoa(mw.statusCode),
+			"bytes":          strconv.FormatInt(mw.bytes, 10),
+			"duration_ms":    strconv.FormatFloat(float64(duration.Microseconds())/1000, 'f', 3, 64),
+			"remote_ip":      app.clientIP(r),
+			"user_agent":     r.UserAgent(),
+		})
+
+		if slow {
+			properties["slow"] = "true"
+		}
+
+		app.logger.PrintInfo("request", properties)
 	})
 }
+
+// metricsResponseWriter remembers the status code and the number of body
+// bytes written through it.
+type metricsResponseWriter struct {
+	wrapped       http.ResponseWriter
+	statusCode    int
+	bytes         int64
+	headerWritten bool
+}
+
+func (mw *metricsResponseWriter) Header() http.Header {
+	return mw.wrapped.Header()
+}
+
+func (mw *metricsResponseWriter) WriteHeader(statusCode int) {
+	mw.wrapped.WriteHeader(statusCode)
+
+	if !mw.headerWritten {
+		mw.statusCode = statusCode
+		mw.headerWritten = true
+	}
+}
+
+func (mw *metricsResponseWriter) Write(b []byte) (int, error) {
+	mw.headerWritten = true
+
+	n, err := mw.wrapped.Write(b)
+	mw.bytes += int64(n)
+
+	return n, err
+}
+
+func (mw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mw.wrapped
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/ratelimit"
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
+	"github.com/betasve/go-commerce/services/auth/pkg/metrics"
+	"github.com/betasve/go-commerce/services/auth/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		generated bool
+	}{
+		{"Keeps a valid id", "checkout-42", false},
+		{"Generates a missing id", "", true},
+		{"Replaces a malformed id", "bad id\n", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &application{}
+
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestid.FromContext(r.Context())
+			})
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/v1/health/live", nil)
+			if tc.header != "" {
+				req.Header.Set(requestid.Header, tc.header)
+			}
+
+			app.requestID(next).ServeHTTP(rr, req)
+
+			echoed := rr.Header().Get(requestid.Header)
+			assert.Equal(t, seen, echoed)
+
+			if tc.generated {
+				assert.NotEqual(t, tc.header, echoed)
+				assert.True(t, requestid.Valid(echoed))
+			} else {
+				assert.Equal(t, tc.header, echoed)
+			}
+		})
+	}
+}
+
+func TestRequestIDOnPanic(t *testing.T) {
+	logs := &bytes.Buffer{}
+	app := &application{logger: jsonlog.New(logs, jsonlog.LevelInfo)}
+
+	handler := app.requestID(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Set(requestid.Header, "checkout-42")
+
+	handler.ServeHTTP(rr, req)
+
+	var body map[string]any
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry struct {
+		Properties map[string]string `json:"properties"`
+	}
+
+	err = json.Unmarshal(logs.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "checkout-42", rr.Header().Get(requestid.Header))
+	assert.Equal(t, "checkout-42", body["request_id"])
+	assert.Equal(t, "checkout-42", entry.Properties["request_id"])
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		slow       time.Duration
+		status     int
+		logged     bool
+	}{
+		{"Logs sampled successes", 1, 0, http.StatusOK, true},
+		{"Skips unsampled successes", 0, 0, http.StatusOK, false},
+		{"Always logs client errors", 0, 0, http.StatusNotFound, true},
+		{"Always logs server errors", 0, 0, http.StatusInternalServerError, true},
+		{"Always logs slow requests", 0, time.Nanosecond, http.StatusOK, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := &bytes.Buffer{}
+			app := &application{logger: jsonlog.New(logs, jsonlog.LevelInfo)}
+			app.config.accessLog.enabled = true
+			app.config.accessLog.sampleRate = tc.sampleRate
+			app.config.accessLog.slowThreshold = tc.slow
+
+			handler := app.accessLog(app.routePattern("/v1/users/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte("hello"))
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/42", nil)
+			req.Header.Set("User-Agent", "test-agent")
+			req = req.WithContext(requestid.NewContext(req.Context(), "checkout-42"))
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tc.logged {
+				assert.Empty(t, logs.String())
+				return
+			}
+
+			var entry struct {
+				Message    string            `json:"message"`
+				Properties map[string]string `json:"properties"`
+			}
+
+			err := json.Unmarshal(logs.Bytes(), &entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "request", entry.Message)
+			assert.Equal(t, "/v1/users/:id", entry.Properties["route"])
+			assert.Equal(t, strconv.Itoa(tc.status), entry.Properties["status"])
+			assert.Equal(t, "5", entry.Properties["bytes"])
+			assert.Equal(t, "192.0.2.1", entry.Properties["remote_ip"])
+			assert.Equal(t, "test-agent", entry.Properties["user_agent"])
+			assert.Equal(t, "checkout-42", entry.Properties["request_id"])
+		})
+	}
+}
+
+func TestAccessLogUnmatchedRoute(t *testing.T) {
+	logs := &bytes.Buffer{}
+	app := &application{logger: jsonlog.New(logs, jsonlog.LevelInfo)}
+	app.config.accessLog.enabled = true
+
+	app.routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+
+	assert.Contains(t, logs.String(), `"route":"unmatched"`)
+	assert.Contains(t, logs.String(), `"status":"404"`)
+}
+
+func TestInstrument(t *testing.T) {
+	app := &application{
+		logger:  jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		metrics: metrics.New("auth"),
+		limiter: ratelimit.NewMemory(),
+	}
+	app.config.env = "test"
+	app.config.limiter.enabled = true
+	app.config.limiter.defaultLimit = ratelimit.Limit{Rate: 1, Burst: 1}
+
+	routes := app.routes()
+
+	for range 2 {
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/health/live", nil))
+	}
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `http_requests_total{method="GET",route="/v1/health/live",service="auth",status="200"} 1`)
+	assert.Contains(t, rr.Body.String(), `http_requests_total{method="GET",route="/v1/health/live",service="auth",status="429"} 1`)
+	assert.Contains(t, rr.Body.String(), `http_rate_limited_total{service="auth"} 1`)
+}
+
+func TestRouteTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(tracing.Propagator())
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	logs := &bytes.Buffer{}
+	app := &application{
+		logger: jsonlog.New(logs, jsonlog.LevelInfo),
+		models: newTestModels(t, false).Instrument(data.Trace(provider.Tracer("test"), "memory")),
+	}
+	app.config.accessLog.enabled = true
+	app.config.accessLog.sampleRate = 1
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	app.routes().ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a query and a server span, got %d", len(spans))
+	}
+
+	query, server := spans[0], spans[1]
+
+	assert.Equal(t, "users.Get", query.Name)
+	assert.Equal(t, server.SpanContext.SpanID(), query.Parent.SpanID())
+
+	assert.Equal(t, "GET /v1/users/:id", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+
+	assert.Contains(t, logs.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+	assert.Contains(t, logs.String(), `"span_id":"`+server.SpanContext.SpanID().String()+`"`)
+}
+
+// failingLimiter stands in for an unreachable shared store.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2025, 3, 26, 15, 4, 5, 0, time.UTC)
+	memory := ratelimit.NewMemory()
+	memory.Now = func() time.Time { return now }
+
+	app := &application{
+		logger:  jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		limiter: memory,
+	}
+	app.config.limiter.enabled = true
+	app.config.limiter.defaultLimit = ratelimit.Limit{Rate: 1, Burst: 2}
+	app.config.limiter.routes = map[string]ratelimit.Limit{
+		"GET /v1/health/live": {},
+		"POST /v1/users":      {Rate: 0.1, Burst: 1},
+	}
+
+	serve := func(route, ip string) *httptest.ResponseRecorder {
+		handler := app.rateLimit(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Sets headers and rejects over the limit", func(t *testing.T) {
+		rr := serve("GET /v1/users", "192.0.2.1")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+
+		serve("GET /v1/users/:id", "192.0.2.1")
+
+		rr = serve("GET /v1/users", "192.0.2.1")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("Keeps routes with a policy apart", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("POST /v1/users", "192.0.2.1").Code)
+
+		rr := serve("POST /v1/users", "192.0.2.1")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("Exempts routes with a zero policy", func(t *testing.T) {
+		for range 5 {
+			rr := serve("GET /v1/health/live", "192.0.2.1")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("Limits each client IP apart", func(t *testing.T) {
+		rr := serve("GET /v1/users", "192.0.2.2")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("Lets requests through when the limiter fails", func(t *testing.T) {
+		app.limiter = failingLimiter{}
+		defer func() { app.limiter = memory }()
+
+		assert.Equal(t, http.StatusOK, serve("GET /v1/users", "192.0.2.1").Code)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	app := &application{}
+	app.config.limiter.trustedProxies = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"Direct client", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"Ignores the header from untrusted peers", "192.0.2.1:1234", []string{"203.0.113.9"}, "192.0.2.1"},
+		{"Trusts one proxy", "10.0.0.2:1234", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"Skips trusted hops", "10.0.0.2:1234", []string{"203.0.113.9, 10.0.0.3"}, "203.0.113.9"},
+		{"Ignores spoofed hops left of the client", "10.0.0.2:1234", []string{"1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"Joins repeated headers", "10.0.0.2:1234", []string{"203.0.113.9", "10.0.0.3"}, "203.0.113.9"},
+		{"Stops at garbage", "10.0.0.2:1234", []string{"203.0.113.9, nonsense"}, "10.0.0.2"},
+		{"IPv6 proxy", "[fd00::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+
+			for _, v := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, tc.expected, app.clientIP(req))
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/db"
+	"github.com/betasve/go-commerce/services/auth/internal/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFiles are the migrations compiled into the binary.
+var migrationFiles = db.Migrations()
+
+const migrateUsage = `usage: gc-auth migrate [flags] <command>
+
+commands:
+  up            apply all pending migrations
+  down N        revert the last N migrations
+  goto V        migrate up or down to version V
+  force V       set the version to V without running anything (clears dirty state)
+  status        show the current version, pending migrations and file issues
+  new NAME      create an empty up/down pair for the next version in -dir
+                (db/migrations by default)
+
+flags:
+`
+
+// migrateCommand holds what the migrate subcommands need. db and migrator are
+// only set up for commands that talk to the database.
+type migrateCommand struct {
+	out    io.Writer
+	dir    string
+	source fs.FS
+	dryRun bool
+	set    *migrations.Set
+
+	db       *sql.DB
+	migrator *migrate.Migrate
+}
+
+func runMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, migrateUsage)
+		fs.PrintDefaults()
+	}
+
+	dsn := fs.String("db-dsn", "", "PostgreSQL DSN")
+	dir := fs.String("dir", "", "Use migrations from this directory instead of the embedded ones")
+	dryRun := fs.Bool("dry-run", false, "Print what would be done without changing anything")
+
+	err := loadCommandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing migrate command")
+	}
+
+	name, rest := fs.Arg(0), fs.Args()[1:]
+
+	cmd := &migrateCommand{out: out, dir: "embedded migrations", source: migrationFiles, dryRun: *dryRun}
+
+	switch {
+	case *dir != "":
+		cmd.dir, cmd.source = *dir, os.DirFS(*dir)
+	case name == "new":
+		cmd.dir, cmd.source = "db/migrations", os.DirFS("db/migrations")
+	}
+
+	cmd.set, err = migrations.Load(cmd.source)
+	if err != nil {
+		return err
+	}
+
+	if name == "new" {
+		if len(rest) != 1 {
+			return errors.New("usage: migrate new NAME")
+		}
+
+		return cmd.create(rest[0])
+	}
+
+	switch name {
+	case "up", "down", "goto", "force", "status":
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %q", name)
+	}
+
+	err = cmd.connect(*dsn)
+	if err != nil {
+		return err
+	}
+	defer cmd.close()
+
+	switch name {
+	case "up":
+		return cmd.up()
+	case "down":
+		n, err := migrateArg(rest, "down N")
+		if err != nil {
+			return err
+		}
+
+		return cmd.down(int(n))
+	case "goto":
+		v, err := migrateArg(rest, "goto V")
+		if err != nil {
+			return err
+		}
+
+		return cmd.gotoVersion(v)
+	case "force":
+		v, err := migrateArg(rest, "force V")
+		if err != nil {
+			return err
+		}
+
+		return cmd.force(v)
+	default:
+		return cmd.status()
+	}
+}
+
+func migrateArg(args []string, usage string) (uint, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("usage: migrate %s", usage)
+	}
+
+	n, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("usage: migrate %s: %q is not a number", usage, args[0])
+	}
+
+	return uint(n), nil
+}
+
+func (c *migrateCommand) connect(dsn string) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := newMigrator(context.Background(), db, c.source)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	c.db = db
+	c.migrator = migrator
+
+	return nil
+}
+
+func (c *migrateCommand) close() {
+	c.migrator.Close()
+	c.db.Close()
+}
+
+func (c *migrateCommand) version() (uint, bool, error) {
+	return migratorVersion(c.migrator)
+}
+
+// current is version() for commands that must not run on a dirty schema.
+func (c *migrateCommand) current() (uint, error) {
+	v, dirty, err := c.version()
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("schema is dirty at version %d, fix it and run migrate force %d", v, v)
+	}
+
+	return v, nil
+}
+
+func (c *migrateCommand) up() error {
+	current, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	return c.apply(c.set.PlanUp(current), true, c.migrator.Up)
+}
+
+func (c *migrateCommand) down(n int) error {
+	current, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	plan, err := c.set.PlanDown(current, n)
+	if err != nil {
+		return err
+	}
+
+	return c.apply(plan, false, func() error { return c.migrator.Steps(-n) })
+}
+
+func (c *migrateCommand) gotoVersion(v uint) error {
+	current, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	plan, up, err := c.set.PlanGoto(current, v)
+	if err != nil {
+		return err
+	}
+
+	if v == 0 {
+		return c.apply(plan, up, c.migrator.Down)
+	}
+
+	return c.apply(plan, up, func() error { return c.migrator.Migrate(v) })
+}
+
+func (c *migrateCommand) force(v uint) error {
+	if v != 0 && !c.set.Has(v) {
+		return fmt.Errorf("unknown version %d", v)
+	}
+
+	if c.dryRun {
+		fmt.Fprintf(c.out, "dry run: would force version to %d\n", v)
+		return nil
+	}
+
+	// golang-migrate uses -1 to mean that no migration is applied.
+	target := int(v)
+	if v == 0 {
+		target = -1
+	}
+
+	err := c.migrator.Force(target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "forced version to %d\n", v)
+
+	return nil
+}
+
+func (c *migrateCommand) apply(plan []migrations.Migration, up bool, run func() error) error {
+	if len(plan) == 0 {
+		fmt.Fprintln(c.out, "no change")
+		return nil
+	}
+
+	direction, file := "up", func(m migrations.Migration) string { return m.Up }
+	if !up {
+		direction, file = "down", func(m migrations.Migration) string { return m.Down }
+	}
+
+	c.printIssues()
+
+	if c.dryRun {
+		fmt.Fprintf(c.out, "dry run: would apply %d migration(s) %s:\n", len(plan), direction)
+	}
+
+	for _, m := range plan {
+		fmt.Fprintf(c.out, "  %s %s\n", direction, file(m))
+	}
+
+	if c.dryRun {
+		return nil
+	}
+
+	err := run()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	v, _, err := c.version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "now at version %d\n", v)
+
+	return nil
+}
+
+func (c *migrateCommand) status() error {
+	v, dirty, err := c.version()
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if dirty {
+		state = "dirty"
+	}
+
+	fmt.Fprintf(c.out, "version: %d (%s)\n", v, state)
+	fmt.Fprintf(c.out, "latest:  %d\n\n", c.set.Latest())
+
+	for _, m := range c.set.Migrations {
+		mark := "pending"
+		if m.Version <= v {
+			mark = "applied"
+		}
+
+		fmt.Fprintf(c.out, "  [%s] %s\n", mark, m)
+	}
+
+	fmt.Fprintln(c.out)
+	c.printIssues()
+
+	return nil
+}
+
+func (c *migrateCommand) create(name string) error {
+	if c.dryRun {
+		fmt.Fprintf(c.out, "dry run: would create version %d %q in %s\n", c.set.Latest()+1, name, c.dir)
+		return nil
+	}
+
+	files, err := migrations.Create(c.dir, c.set, name)
+	for _, f := range files {
+		fmt.Fprintf(c.out, "created %s\n", f)
+	}
+
+	return err
+}
+
+func (c *migrateCommand) printIssues() {
+	if len(c.set.Issues) == 0 {
+		return
+	}
+
+	fmt.Fprintf(c.out, "%d issue(s) in %s:\n", len(c.set.Issues), c.dir)
+
+	for _, issue := range c.set.Issues {
+		fmt.Fprintf(c.out, "  - %s\n", issue)
+	}
+}
+
+// newMigrator runs on its own connection so that closing the migrator does
+// not close the pool it came from. If ctx has a deadline, neither waiting
+// for the migration lock nor any one statement may run past it; golang-migrate
+// otherwise ignores ctx once the migrator exists.
+func newMigrator(ctx context.Context, pool *sql.DB, source fs.FS) (*migrate.Migrate, error) {
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+
+	var driverConfig postgres.Config
+	if hasDeadline {
+		driverConfig.StatementTimeout = time.Until(deadline)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &driverConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	src, err := iofs.New(source, ".")
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	migrator, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return nil, err
+	}
+
+	if hasDeadline {
+		migrator.LockTimeout = time.Until(deadline)
+	}
+
+	return migrator, nil
+}
+
+// autoMigrate applies the pending embedded migrations and returns the
+// versions before and after. Replicas starting at the same time wait for
+// each other on the advisory lock golang-migrate takes around Up, so the
+// migrator needs only the one connection. No new migration starts once ctx
+// is done.
+func autoMigrate(ctx context.Context, pool *sql.DB) (from, to uint, err error) {
+	migrator, err := newMigrator(ctx, pool, migrationFiles)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer migrator.Close()
+
+	from, _, err = migratorVersion(migrator)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			migrator.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
+	err = migrator.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return from, from, err
+	}
+
+	to, _, err = migratorVersion(migrator)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return from, to, err
+}
+
+// migratorVersion returns the current schema version, 0 meaning nothing is
+// applied yet.
+func migratorVersion(m *migrate.Migrate) (uint, bool, error) {
+	v, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return v, dirty, err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The DSNs point at a closed port, so the error shows which one was used.
+func TestRunMigrateConfig(t *testing.T) {
+	if _, ok := os.LookupEnv("GO_COMMERCE_DB_DSN"); ok {
+		t.Skip("GO_COMMERCE_DB_DSN would win over the settings under test")
+	}
+
+	t.Run("DATABASE_URL", func(t *testing.T) {
+		t.Setenv("DATABASE_URL", "postgres://auth@127.0.0.1:1/from_alias?sslmode=disable&connect_timeout=1")
+
+		err := runMigrate([]string{"status"}, &bytes.Buffer{})
+
+		assert.ErrorContains(t, err, "127.0.0.1:1")
+	})
+
+	t.Run("config file shared with the server", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "auth.yaml")
+		content := "port: 4000\ndb:\n  dsn: postgres://auth@127.0.0.1:2/from_file?sslmode=disable&connect_timeout=1\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		err := runMigrate([]string{"-config", path, "status"}, &bytes.Buffer{})
+
+		assert.ErrorContains(t, err, "127.0.0.1:2")
+	})
+}
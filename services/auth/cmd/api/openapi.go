@@ -0,0 +1,489 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/betasve/go-commerce/services/auth/internal/health"
+	"github.com/betasve/go-commerce/services/auth/internal/openapi"
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI holds the assets of swagger-ui-dist 5.18.2, served by the API so
+// that the docs page loads nothing from third-party hosts.
+//
+//go:embed swaggerui/swagger-ui.css swaggerui/swagger-ui-bundle.js
+var swaggerUI embed.FS
+
+// spec and specJSON are built once, the spec does not change while running.
+var (
+	spec     = sync.OnceValue(apiSpec)
+	specJSON = sync.OnceValues(func() ([]byte, error) {
+		return json.Marshal(spec())
+	})
+)
+
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := specJSON()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+func (app *application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// docsAssetHandler serves one of the swaggerUI files.
+func (app *application) docsAssetHandler(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asset, err := swaggerUI.ReadFile("swaggerui/" + name)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(asset)
+	}
+}
+
+const jsonContentType = "application/json"
+
+// jsonContent describes a JSON body matching schema.
+func jsonContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{jsonContentType: {Schema: schema}}
+}
+
+func specErrorResponse(description string) *openapi.Response {
+	return &openapi.Response{
+		Description: description,
+		Content: map[string]openapi.MediaType{
+			jsonContentType:    {Schema: openapi.Ref("Error")},
+			problemContentType: {Schema: openapi.Ref("Problem")},
+		},
+	}
+}
+
+func responseRef(name string) *openapi.Response {
+	return &openapi.Response{Ref: "#/components/responses/" + name}
+}
+
+func parameterRef(name string) openapi.Parameter {
+	return openapi.Parameter{Ref: "#/components/parameters/" + name}
+}
+
+func stringSchema(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "string", Description: description}
+}
+
+func intSchema(min, max float64) *openapi.Schema {
+	return &openapi.Schema{Type: "integer", Minimum: openapi.Ptr(min), Maximum: openapi.Ptr(max)}
+}
+
+// rateLimitHeaders are set on every response from a rate limited route.
+var rateLimitHeaders = map[string]openapi.Header{
+	"RateLimit-Limit":     {Description: "Requests allowed in a burst.", Schema: &openapi.Schema{Type: "integer"}},
+	"RateLimit-Remaining": {Description: "Requests left in the current burst.", Schema: &openapi.Schema{Type: "integer"}},
+	"RateLimit-Reset":     {Description: "Seconds until the burst is fully available again.", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+var etagHeader = map[string]openapi.Header{
+	"ETag":          {Description: "Version of the user, to send back in If-Match or If-None-Match.", Schema: &openapi.Schema{Type: "string"}},
+	"Last-Modified": {Description: "When the user was last updated.", Schema: &openapi.Schema{Type: "string"}},
+}
+
+// apiSpec describes every route in routeTable. Keep it next to any change
+// of a handler's input or output.
+func apiSpec() *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "go-commerce auth service",
+			Version:     version,
+			Description: "Users and roles of go-commerce. Errors are {\"error\": ...} by default, or RFC 7807 problem+json when requested in Accept.",
+		},
+		Servers: []openapi.Server{{URL: "http://localhost:4000", Description: "Local development"}},
+		Components: openapi.Components{
+			Schemas:    specSchemas(),
+			Parameters: specParameters(),
+			Responses: map[string]*openapi.Response{
+				"BadRequest":           specErrorResponse("The body is not valid JSON or has unknown fields."),
+				"NotFound":             specErrorResponse("The resource does not exist."),
+				"Conflict":             specErrorResponse("The resource changed while the request was processed, or the Idempotency-Key is in use or was used for a different request."),
+				"PreconditionFailed":   specErrorResponse("If-Match does not match the current ETag."),
+				"PreconditionRequired": specErrorResponse("If-Match is missing."),
+				"RateLimited": {
+					Description: "The caller exceeded its rate limit.",
+					Headers: map[string]openapi.Header{
+						"Retry-After": {Description: "Seconds to wait before retrying.", Schema: &openapi.Schema{Type: "integer"}},
+					},
+					Content: specErrorResponse("").Content,
+				},
+				"ServerError": specErrorResponse("Unexpected server error."),
+				"NotModified": {Description: "The cached copy named in If-None-Match or If-Modified-Since is current."},
+				"ValidationFailed": {
+					Description: "One or more fields are invalid.",
+					Content: map[string]openapi.MediaType{
+						jsonContentType:    {Schema: openapi.Ref("ValidationError")},
+						problemContentType: {Schema: openapi.Ref("Problem")},
+					},
+				},
+			},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Access tokens issued by this service. Not enforced yet: no route requires authentication.",
+				},
+			},
+		},
+	}
+
+	withDefaults := func(op *openapi.Operation) *openapi.Operation {
+		op.Responses["429"] = responseRef("RateLimited")
+		op.Responses["500"] = responseRef("ServerError")
+		return op
+	}
+
+	doc.AddOperation(http.MethodGet, "/v1/health/live", &openapi.Operation{
+		OperationID: "getLiveness",
+		Summary:     "Report that the process is serving requests",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Alive.", Content: jsonContent(openapi.Ref("Liveness"))},
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/v1/healthcheck", &openapi.Operation{
+		OperationID: "getHealthcheck",
+		Summary:     "Same as /v1/health/live, with the status it had before",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Alive.", Content: jsonContent(openapi.Ref("Healthcheck"))},
+		},
+		Deprecated: true,
+	})
+
+	doc.AddOperation(http.MethodGet, "/v1/health/ready", &openapi.Operation{
+		OperationID: "getReadiness",
+		Summary:     "Report whether the dependencies are usable",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Ready to serve traffic.", Content: jsonContent(openapi.Ref("Readiness"))},
+			"503": {Description: "A dependency is down, or the server is draining.", Content: jsonContent(openapi.Ref("Readiness"))},
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/v1/openapi.json", withDefaults(&openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This document",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The OpenAPI document.", Content: jsonContent(&openapi.Schema{Type: "object"})},
+		},
+	}))
+
+	doc.AddOperation(http.MethodGet, "/v1/docs", withDefaults(&openapi.Operation{
+		OperationID: "getDocs",
+		Summary:     "Swagger UI for this document",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "An HTML page.", Content: map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	}))
+
+	doc.AddOperation(http.MethodGet, "/v1/docs/swagger-ui.css", withDefaults(&openapi.Operation{
+		OperationID: "getDocsStylesheet",
+		Summary:     "Stylesheet of the Swagger UI",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "A stylesheet.", Content: map[string]openapi.MediaType{"text/css": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	}))
+
+	doc.AddOperation(http.MethodGet, "/v1/docs/swagger-ui-bundle.js", withDefaults(&openapi.Operation{
+		OperationID: "getDocsScript",
+		Summary:     "Script of the Swagger UI",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "A script.", Content: map[string]openapi.MediaType{"text/javascript": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	}))
+
+	doc.AddOperation(http.MethodGet, "/v1/users", withDefaults(&openapi.Operation{
+		OperationID: "listUsers",
+		Summary:     "List users",
+		Tags:        []string{"users"},
+		Parameters: []openapi.Parameter{
+			{Name: "email", In: "query", Description: "Case-insensitive exact email.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "name", In: "query", Description: "Words that must all appear in the name.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "page", In: "query", Schema: intSchema(1, 10_000_000)},
+			{Name: "page_size", In: "query", Schema: intSchema(1, 100)},
+			{Name: "sort", In: "query", Description: "Field to sort by, prefixed with - for descending order.", Schema: &openapi.Schema{Type: "string", Enum: anySlice(userSortSafeList)}},
+			parameterRef("IfNoneMatch"),
+		},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "A page of users. The weak ETag changes with the content.", Headers: rateLimitHeaders, Content: jsonContent(openapi.Ref("UserList"))},
+			"304": responseRef("NotModified"),
+			"422": responseRef("ValidationFailed"),
+		},
+	}))
+
+	doc.AddOperation(http.MethodPost, "/v1/users", withDefaults(&openapi.Operation{
+		OperationID: "createUser",
+		Summary:     "Create a user",
+		Tags:        []string{"users"},
+		Parameters:  []openapi.Parameter{parameterRef("IdempotencyKey")},
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonContent(openapi.Ref("CreateUserInput"))},
+		Responses: map[string]*openapi.Response{
+			"201": {
+				Description: "The user was created.",
+				Headers: map[string]openapi.Header{
+					"Location": {Description: "URL of the new user.", Schema: &openapi.Schema{Type: "string"}},
+				},
+				Content: jsonContent(openapi.Ref("UserEnvelope")),
+			},
+			"400": responseRef("BadRequest"),
+			"409": responseRef("Conflict"),
+			"422": responseRef("ValidationFailed"),
+		},
+	}))
+
+	doc.AddOperation(http.MethodGet, "/v1/users/{id}", withDefaults(&openapi.Operation{
+		OperationID: "showUser",
+		Summary:     "Show a user",
+		Tags:        []string{"users"},
+		Parameters:  []openapi.Parameter{parameterRef("UserID"), parameterRef("IfNoneMatch")},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The user.", Headers: etagHeader, Content: jsonContent(openapi.Ref("UserEnvelope"))},
+			"304": responseRef("NotModified"),
+			"404": responseRef("NotFound"),
+			"422": responseRef("ValidationFailed"),
+		},
+	}))
+
+	doc.AddOperation(http.MethodPatch, "/v1/users/{id}", withDefaults(&openapi.Operation{
+		OperationID: "updateUser",
+		Summary:     "Update some fields of a user",
+		Tags:        []string{"users"},
+		Parameters: []openapi.Parameter{
+			parameterRef("UserID"),
+			parameterRef("IdempotencyKey"),
+			{Name: "If-Match", In: "header", Required: true, Description: "ETag from the last read of the user.", Schema: &openapi.Schema{Type: "string"}},
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: jsonContent(openapi.Ref("UpdateUserInput"))},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The updated user.", Headers: etagHeader, Content: jsonContent(openapi.Ref("UserEnvelope"))},
+			"400": responseRef("BadRequest"),
+			"404": responseRef("NotFound"),
+			"409": responseRef("Conflict"),
+			"412": responseRef("PreconditionFailed"),
+			"422": responseRef("ValidationFailed"),
+			"428": responseRef("PreconditionRequired"),
+		},
+	}))
+
+	doc.AddOperation(http.MethodDelete, "/v1/users/{id}", withDefaults(&openapi.Operation{
+		OperationID: "deleteUser",
+		Summary:     "Delete a user",
+		Tags:        []string{"users"},
+		Parameters:  []openapi.Parameter{parameterRef("UserID")},
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "The user was deleted."},
+			"404": responseRef("NotFound"),
+			"422": responseRef("ValidationFailed"),
+		},
+	}))
+
+	return doc
+}
+
+func specParameters() map[string]*openapi.Parameter {
+	return map[string]*openapi.Parameter{
+		"UserID":         {Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Ptr(1.0)}},
+		"IdempotencyKey": {Name: "Idempotency-Key", In: "header", Description: "Unique key, such as a UUID, that makes retries of the request safe. Retries with the same key and payload replay the first response, marked with Idempotent-Replayed: true.", Schema: &openapi.Schema{Type: "string", MinLength: openapi.Ptr(1), MaxLength: openapi.Ptr(255)}},
+		"IfNoneMatch":    {Name: "If-None-Match", In: "header", Description: "ETag of a cached copy, answered with 304 while it is current.", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+func specSchemas() map[string]*openapi.Schema {
+	name := &openapi.Schema{Type: "string", MinLength: openapi.Ptr(6), Pattern: validator.NameRX.String()}
+	email := &openapi.Schema{Type: "string", Format: "email", MinLength: openapi.Ptr(1), Pattern: validator.EmailRX.String()}
+	password := &openapi.Schema{Type: "string", MinLength: openapi.Ptr(8), MaxLength: openapi.Ptr(72), Format: "password"}
+
+	count := func() *openapi.Schema { return &openapi.Schema{Type: "integer", Minimum: openapi.Ptr(1.0)} }
+
+	return map[string]*openapi.Schema{
+		"User": {
+			Type:     "object",
+			Required: []string{"id", "name", "email", "password", "created_at", "updated_at"},
+			Properties: map[string]*openapi.Schema{
+				"id":         {Type: "integer", Format: "int64"},
+				"name":       {Type: "string"},
+				"email":      {Type: "string", Format: "email"},
+				"password":   {Type: "string", Const: "[FILTERED]", Description: "Never returned."},
+				"created_at": {Type: "string", Format: "date-time"},
+				"updated_at": {Type: "string", Format: "date-time"},
+			},
+		},
+		"UserEnvelope": {
+			Type:       "object",
+			Required:   []string{"user"},
+			Properties: map[string]*openapi.Schema{"user": openapi.Ref("User")},
+		},
+		"UserList": {
+			Type:     "object",
+			Required: []string{"users", "metadata"},
+			Properties: map[string]*openapi.Schema{
+				"users":    {Type: "array", Items: openapi.Ref("User")},
+				"metadata": openapi.Ref("Metadata"),
+			},
+		},
+		"Metadata": {
+			Type:        "object",
+			Description: "Pagination details. Empty when there are no results.",
+			Properties: map[string]*openapi.Schema{
+				"current_page":  count(),
+				"page_size":     count(),
+				"first_page":    count(),
+				"last_page":     count(),
+				"total_records": count(),
+			},
+		},
+		"CreateUserInput": {
+			Type:                 "object",
+			Required:             []string{"name", "email", "password"},
+			AdditionalProperties: openapi.Nothing(),
+			Properties: map[string]*openapi.Schema{
+				"name":     name,
+				"email":    email,
+				"password": password,
+			},
+		},
+		"UpdateUserInput": {
+			Type:                 "object",
+			AdditionalProperties: openapi.Nothing(),
+			Properties: map[string]*openapi.Schema{
+				"name":     name,
+				"email":    email,
+				"password": password,
+			},
+		},
+		"Error": {
+			Type:     "object",
+			Required: []string{"error"},
+			Properties: map[string]*openapi.Schema{
+				"error":      {Type: "string"},
+				"request_id": {Type: "string"},
+			},
+		},
+		"ValidationError": {
+			Type:     "object",
+			Required: []string{"error"},
+			Properties: map[string]*openapi.Schema{
+				"error": {
+					Type:                 "object",
+					Description:          "Messages keyed by field name.",
+					AdditionalProperties: &openapi.Schema{Type: "string"},
+				},
+				"request_id": {Type: "string"},
+			},
+		},
+		"Problem": {
+			Type:     "object",
+			Required: []string{"type", "title", "status"},
+			Properties: map[string]*openapi.Schema{
+				"type":     {Type: "string", Format: "uri"},
+				"title":    {Type: "string"},
+				"status":   {Type: "integer"},
+				"detail":   {Type: "string"},
+				"instance": {Type: "string", Description: "The request ID."},
+				"errors": {
+					Type: "array",
+					Items: &openapi.Schema{
+						Type:     "object",
+						Required: []string{"field", "detail"},
+						Properties: map[string]*openapi.Schema{
+							"field":  {Type: "string"},
+							"detail": {Type: "string"},
+						},
+					},
+				},
+			},
+		},
+		"Liveness": {
+			Type:     "object",
+			Required: []string{"status", "system_info"},
+			Properties: map[string]*openapi.Schema{
+				"status": {Type: "string", Const: "alive"},
+				"system_info": {
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						"environment": {Type: "string"},
+						"version":     {Type: "string"},
+					},
+				},
+			},
+		},
+		"Healthcheck": {
+			Type:     "object",
+			Required: []string{"status", "system_info"},
+			Properties: map[string]*openapi.Schema{
+				"status": {Type: "string", Const: "available"},
+				"system_info": {
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						"environment": {Type: "string"},
+						"version":     {Type: "string"},
+					},
+				},
+			},
+		},
+		"Readiness": {
+			Type:     "object",
+			Required: []string{"health"},
+			Properties: map[string]*openapi.Schema{
+				"health": {
+					Type:     "object",
+					Required: []string{"status", "checked_at", "components"},
+					Properties: map[string]*openapi.Schema{
+						"status":     {Type: "string", Enum: []any{health.StatusReady, health.StatusNotReady, health.StatusDraining}},
+						"checked_at": {Type: "string", Format: "date-time"},
+						"components": {
+							Type: "object",
+							AdditionalProperties: &openapi.Schema{
+								Type:     "object",
+								Required: []string{"status", "latency_ms"},
+								Properties: map[string]*openapi.Schema{
+									"status":     {Type: "string", Enum: []any{health.StatusUp, health.StatusDown}},
+									"latency_ms": {Type: "number"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func anySlice(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+
+	return out
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/betasve/go-commerce/services/auth/internal/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	app := application{}
+	spec := apiSpec()
+
+	var routes []string
+	for _, rt := range app.routeTable() {
+		routes = append(routes, rt.method+" "+openapi.PathTemplate(rt.pattern))
+
+		t.Run(rt.method+" "+rt.pattern, func(t *testing.T) {
+			assert.NotNil(t, spec.Operation(rt.method, openapi.PathTemplate(rt.pattern)), "route is missing from apiSpec")
+		})
+	}
+
+	assert.ElementsMatch(t, routes, spec.Operations(), "apiSpec describes a route that is not registered")
+}
+
+func TestSpecReferences(t *testing.T) {
+	spec := apiSpec()
+
+	js, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var components map[string]map[string]json.RawMessage
+	b, _ := json.Marshal(spec.Components)
+	json.Unmarshal(b, &components)
+
+	// Every $ref in the document must point at a component that exists.
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				if assert.Len(t, parts, 2, ref) {
+					assert.Contains(t, components[parts[0]], parts[1], ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+
+	var doc any
+	assert.NoError(t, json.Unmarshal(js, &doc))
+	walk(doc)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	app := application{}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil)
+
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/v1/users/{id}")
+}
+
+func TestDocsHandler(t *testing.T) {
+	app := application{}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/docs", nil)
+
+	app.routes().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `url: "/v1/openapi.json"`)
+	assert.NotContains(t, rr.Body.String(), "https://")
+}
+
+func TestDocsAssets(t *testing.T) {
+	app := application{}
+	routes := app.routes()
+
+	tests := []struct {
+		url         string
+		contentType string
+		contains    string
+	}{
+		{"/v1/docs/swagger-ui.css", "text/css; charset=utf-8", ".swagger-ui"},
+		{"/v1/docs/swagger-ui-bundle.js", "text/javascript; charset=utf-8", "SwaggerUIBundle"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Body.String(), tc.contains)
+		})
+	}
+}
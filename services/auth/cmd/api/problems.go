@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/betasve/go-commerce/services/auth/internal/requestid"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemType identifies a kind of error. The URIs are stable, so clients can
+// switch on them instead of on the English detail, which may change.
+type problemType struct {
+	uri   string
+	title string
+}
+
+func newProblemType(slug, title string) problemType {
+	return problemType{uri: "urn:go-commerce:problem:" + slug, title: title}
+}
+
+var (
+	problemBadRequest           = newProblemType("bad-request", "Malformed request")
+	problemEditConflict         = newProblemType("edit-conflict", "Edit conflict")
+	problemValidation           = newProblemType("validation-failed", "Validation failed")
+	problemNotFound             = newProblemType("not-found", "Resource not found")
+	problemMethodNotAllowed     = newProblemType("method-not-allowed", "Method not allowed")
+	problemServerError          = newProblemType("server-error", "Internal server error")
+	problemPreconditionFailed   = newProblemType("precondition-failed", "Precondition failed")
+	problemPreconditionRequired = newProblemType("precondition-required", "Precondition required")
+	problemRateLimited          = newProblemType("rate-limited", "Rate limit exceeded")
+	problemIdempotencyKeyInUse  = newProblemType("idempotency-key-in-use", "Idempotency key in use")
+	problemIdempotencyKeyReused = newProblemType("idempotency-key-reused", "Idempotency key reused")
+)
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Errors   []problemField `json:"errors,omitempty"`
+}
+
+type problemField struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// wantsProblem reports whether the response should be problem+json: either
+// every error is, or the client asked for it in Accept.
+func (app *application) wantsProblem(r *http.Request) bool {
+	if app.config.errorsFormat == "problem" {
+		return true
+	}
+
+	for _, accepted := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err == nil && mediaType == problemContentType && !refused(params) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refused reports whether the q-value of a media range is zero, e.g. "0.0".
+// A q-value that does not parse is ignored, as if it were missing.
+func refused(params map[string]string) bool {
+	q, err := strconv.ParseFloat(params["q"], 64)
+
+	return err == nil && q == 0
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, status int, typ problemType, message interface{}) error {
+	p := problem{
+		Type:     typ.uri,
+		Title:    typ.title,
+		Status:   status,
+		Instance: requestid.FromContext(r.Context()),
+	}
+
+	switch m := message.(type) {
+	case map[string]string:
+		p.Detail = "one or more fields are invalid"
+
+		for field, detail := range m {
+			p.Errors = append(p.Errors, problemField{Field: field, Detail: detail})
+		}
+
+		sort.Slice(p.Errors, func(i, j int) bool { return p.Errors[i].Field < p.Errors[j].Field })
+	case string:
+		p.Detail = m
+	}
+
+	js, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+
+	return nil
+}
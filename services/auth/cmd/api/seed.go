@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/seed"
+)
+
+const seedUsage = `usage: gc-auth seed [flags]
+
+Loads fixture files and/or generated users. Users are matched by email, so
+running the same seed again leaves the database unchanged.
+
+flags:
+`
+
+func runSeed(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, seedUsage)
+		fs.PrintDefaults()
+	}
+
+	var files []string
+
+	dsn := fs.String("db-dsn", "", "PostgreSQL DSN")
+	fs.Func("file", "YAML or JSON fixtures file (repeatable)", func(val string) error {
+		files = append(files, val)
+		return nil
+	})
+	fake := fs.Int("fake", 0, "Number of fake customers to generate")
+	fakeSeed := fs.Uint64("fake-seed", 1, "Random seed for fake customers")
+	fakePassword := fs.String("fake-password", "Password123!", "Password for fake customers")
+
+	err := loadCommandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *fake < 0 {
+		fs.Usage()
+		return errors.New("-fake must not be negative")
+	}
+
+	if len(files) == 0 && *fake == 0 {
+		fs.Usage()
+		return errors.New("nothing to seed, pass -file and/or -fake")
+	}
+
+	fixtures := &seed.Fixtures{}
+
+	for _, file := range files {
+		f, err := seed.LoadFile(file)
+		if err != nil {
+			return err
+		}
+
+		fixtures.Users = append(fixtures.Users, f.Users...)
+	}
+
+	fixtures.Users = append(fixtures.Users, seed.FakeUsers(*fake, *fakeSeed, *fakePassword)...)
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return err
+	}
+
+	router := data.NewRouter(db, nil)
+	defer router.Close()
+
+	seeder := seed.Seeder{
+		Models:  data.NewModels(router),
+		Workers: runtime.GOMAXPROCS(0),
+	}
+
+	result, err := seeder.Apply(context.Background(), fixtures)
+
+	fmt.Fprintf(out, "users: %s\n", result)
+
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These cases fail before the database is opened.
+func TestRunSeedUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"nothing to seed", nil, "nothing to seed, pass -file and/or -fake"},
+		{"negative fake", []string{"-fake", "-1"}, "-fake must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := runSeed(tt.args, &out)
+
+			assert.EqualError(t, err, tt.err)
+			assert.Contains(t, out.String(), "-fake")
+		})
+	}
+}
+
+func TestRunSeedDatabaseURL(t *testing.T) {
+	if _, ok := os.LookupEnv("GO_COMMERCE_DB_DSN"); ok {
+		t.Skip("GO_COMMERCE_DB_DSN would win over DATABASE_URL")
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://auth@127.0.0.1:1/auth?sslmode=disable&connect_timeout=1")
+
+	err := runSeed([]string{"-fake", "1"}, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "127.0.0.1:1")
+}
@@ -2,28 +2,66 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/tlsconfig"
 )
 
 func (app *application) serve() error {
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
-		ErrorLog:     log.New(app.logger, "", 0),
+		ErrorLog:     app.logger.LogLogger(jsonlog.LevelWarn),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
 
+	if app.config.tls.certFile != "" {
+		certs, err := tlsconfig.New(tlsconfig.Config{
+			CertFile:     app.config.tls.certFile,
+			KeyFile:      app.config.tls.keyFile,
+			ClientCAFile: app.config.tls.clientCAFile,
+			ClientAuth:   app.config.tls.clientAuth,
+			MinVersion:   app.config.tls.minVersion,
+		})
+		if err != nil {
+			return err
+		}
+
+		srv.TLSConfig = certs.TLSConfig()
+
+		err = app.tasks.Go("reload-tls-certificates", func(ctx context.Context) {
+			app.reloadCertificates(ctx, certs, app.config.tls.reloadInterval)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var admin *http.Server
+
+	if app.config.adminPort != 0 {
+		admin = &http.Server{
+			Addr:         net.JoinHostPort(app.config.adminHost, strconv.Itoa(app.config.adminPort)),
+			Handler:      app.adminRoutes(),
+			ErrorLog:     app.logger.LogLogger(jsonlog.LevelWarn),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		}
+	}
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -37,18 +75,47 @@ func (app *application) serve() error {
 			"signal": s.String(),
 		})
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		shutdownError <- srv.Shutdown(ctx)
+		shutdownError <- app.shutdown(srv, admin)
 	}()
 
+	// An admin server that cannot serve, e.g. because its port is taken,
+	// is as fatal as the API server failing, so it closes srv to get the
+	// error back to the caller.
+	adminError := make(chan error, 1)
+
+	if admin != nil {
+		go func() {
+			app.logger.PrintInfo("starting admin server", map[string]string{
+				"addr": admin.Addr,
+			})
+
+			err := admin.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				adminError <- err
+				srv.Close()
+			}
+		}()
+	}
+
 	app.logger.PrintInfo("starting server", map[string]string{
 		"env":  app.config.env,
 		"port": strconv.Itoa(app.config.port),
+		"tls":  strconv.FormatBool(srv.TLSConfig != nil),
 	})
 
-	err := srv.ListenAndServe()
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	select {
+	case err := <-adminError:
+		return fmt.Errorf("admin server: %w", err)
+	default:
+	}
+
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -66,14 +133,89 @@ func (app *application) serve() error {
 	return nil
 }
 
-func (app *application) migrateDB(db *sql.DB) {
-	if app.config.db.migrate == "true" {
-		err := migrateDB(db)
+// shutdown drains the servers, then the background tasks. admin may be nil.
+// It all fits in -shutdown-timeout, which has to end before the orchestrator
+// runs out of patience and kills the process, skipping main's deferred
+// closes: the last -shutdown-task-timeout of it is kept for the tasks, and
+// they get whatever the servers leave of the rest too.
+func (app *application) shutdown(srv, admin *http.Server) error {
+	deadline := time.Now().Add(app.config.shutdown.timeout)
+
+	// Fail readiness first and keep serving for a while, so that load
+	// balancers stop sending traffic before connections are refused.
+	app.health.Drain()
+	time.Sleep(app.config.health.drainDelay)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-app.config.shutdown.taskTimeout))
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+
+	if admin != nil {
+		err = errors.Join(err, admin.Shutdown(ctx))
+	}
+
+	// Requests can start background work until the server has stopped, so
+	// the tasks are only drained afterwards.
+	app.logger.PrintInfo("completing background tasks", map[string]string{
+		"running": strings.Join(app.tasks.Running(), ","),
+	})
 
+	tasksCtx, tasksCancel := context.WithDeadline(context.Background(), deadline)
+	defer tasksCancel()
+
+	// The runner logs each task it abandons at the deadline. Those are not
+	// a reason to fail the shutdown, which would skip main's deferred
+	// closes, so only the servers' errors are returned.
+	_ = app.tasks.Shutdown(tasksCtx)
+
+	return err
+}
+
+// reloadCertificates reloads the TLS files on SIGHUP and whenever a check
+// every interval finds them changed, until ctx is done. A failed reload is
+// logged and the current certificate kept, so a bad rotation does not stop
+// the server.
+func (app *application) reloadCertificates(ctx context.Context, certs *tlsconfig.Reloader, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		reason := "file change"
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reason = "SIGHUP"
+		case <-tick:
+			changed, err := certs.Changed()
+			if err != nil {
+				app.logger.Error(err, jsonlog.String("action", "check TLS files"))
+				continue
+			}
+			if !changed {
+				continue
+			}
+		}
+
+		err := certs.Reload()
 		if err != nil {
-			app.logger.PrintFatal(err, nil)
-		} else {
-			os.Exit(0)
+			app.logger.Error(err, jsonlog.String("action", "reload TLS files"), jsonlog.String("reason", reason))
+			continue
 		}
+
+		app.logger.Info("TLS certificate reloaded",
+			jsonlog.String("reason", reason),
+			jsonlog.Time("not_after", certs.NotAfter()),
+		)
 	}
 }
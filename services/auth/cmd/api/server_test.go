@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/background"
+	"github.com/betasve/go-commerce/services/auth/internal/health"
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/betasve/go-commerce/services/auth/internal/tlsconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		taskTime    time.Duration
+		finished    bool
+		expectedLog string
+	}{
+		{"Waits for background tasks", 10 * time.Millisecond, true, ""},
+		{"Abandons slow background tasks", time.Minute, false, `"task":"send email"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := &bytes.Buffer{}
+			logger := jsonlog.New(logs, jsonlog.LevelInfo)
+
+			app := &application{
+				logger: logger,
+				health: health.NewChecker(0),
+				tasks:  background.New(logger),
+			}
+			app.config.shutdown.timeout = 100 * time.Millisecond
+			app.config.shutdown.taskTimeout = 50 * time.Millisecond
+
+			srv := httptest.NewServer(http.HandlerFunc(app.readinessHandler))
+			defer srv.Close()
+
+			release := make(chan struct{})
+			defer close(release)
+
+			// Sending an email ignores the cancellation, to finish in time
+			// if it can.
+			var finished atomic.Bool
+			app.tasks.Go("send email", func(ctx context.Context) {
+				select {
+				case <-time.After(tc.taskTime):
+					finished.Store(true)
+				case <-release:
+				}
+			})
+
+			err := app.shutdown(srv.Config, nil)
+
+			assert.False(t, app.health.Check(context.Background()).Ready())
+
+			// Abandoned tasks are logged, not returned, so that main still
+			// runs its deferred closes and exits cleanly.
+			assert.NoError(t, err)
+			assert.Equal(t, tc.finished, finished.Load())
+			assert.Contains(t, logs.String(), tc.expectedLog)
+		})
+	}
+}
+
+// writeSelfSigned writes a certificate for localhost and its key to dir.
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestServeAdminError(t *testing.T) {
+	// Taking the admin port makes the admin server fail to start.
+	taken, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	logger := jsonlog.New(io.Discard, jsonlog.LevelInfo)
+
+	app := &application{
+		logger: logger,
+		health: health.NewChecker(0),
+		tasks:  background.New(logger),
+	}
+	app.config.adminPort = taken.Addr().(*net.TCPAddr).Port
+
+	done := make(chan error, 1)
+	go func() { done <- app.serve() }()
+
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "admin server")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected serve to fail when the admin server cannot start")
+	}
+}
+
+func TestReloadCertificatesStopsOnShutdown(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t, t.TempDir())
+
+	certs, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &bytes.Buffer{}
+	logger := jsonlog.New(logs, jsonlog.LevelInfo)
+
+	app := &application{logger: logger, tasks: background.New(logger)}
+
+	err = app.tasks.Go("reload-tls-certificates", func(ctx context.Context) {
+		app.reloadCertificates(ctx, certs, time.Millisecond)
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, app.tasks.Shutdown(ctx))
+	assert.NotContains(t, logs.String(), "abandoned background task")
+}
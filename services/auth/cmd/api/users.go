@@ -9,6 +9,10 @@ import (
 	"github.com/betasve/go-commerce/services/auth/internal/validator"
 )
 
+// userSortSafeList are the values the sort query parameter of the user list
+// accepts.
+var userSortSafeList = []string{"id", "email", "name", "created_at", "updated_at", "-id", "-email", "-name", "-created_at", "-updated_at"}
+
 func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Name     string `json:"name"`
@@ -32,7 +36,7 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	err = app.models.Users.Insert(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -69,14 +73,14 @@ func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "email", "name", "created_at", "updated_at", "-id", "-email", "-name", "-created_at", "-updated_at"}
+	input.Filters.SortSafeList = userSortSafeList
 
 	if data.ValidateFilters(v, input.Filters); v.Invalid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	users, metadata, err := app.models.Users.GetAll(input.Email, input.Name, input.Filters)
+	users, metadata, err := app.models.Users.GetAll(r.Context(), input.Email, input.Name, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -97,7 +101,7 @@ func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	user, err := app.models.Users.Get(id)
+	user, err := app.models.Users.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -108,7 +112,11 @@ func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(user.Version))
+	headers.Set("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -121,7 +129,11 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := app.models.Users.Get(id)
+	// The version If-Match is checked against, and the fields the input is
+	// merged onto, must not come from a lagging replica.
+	ctx := data.WithPrimary(r.Context())
+
+	user, err := app.models.Users.Get(ctx, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -129,6 +141,19 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
+		return
+	}
+
+	present, matches := app.ifMatch(r, versionETag(user.Version))
+	if !present {
+		app.preconditionRequiredResponse(w, r)
+		return
+	}
+
+	if !matches {
+		w.Header().Set("ETag", versionETag(user.Version))
+		app.preconditionFailedResponse(w, r)
+		return
 	}
 
 	var input struct {
@@ -162,11 +187,17 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Update(user)
+	err = app.models.Users.Update(ctx, user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
+			if current, err := app.models.Users.Get(ctx, id); err == nil {
+				w.Header().Set("ETag", versionETag(current.Version))
+			}
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -174,7 +205,11 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(user.Version))
+	headers.Set("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -187,7 +222,7 @@ func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Delete(id)
+	err = app.models.Users.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
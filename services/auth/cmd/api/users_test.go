@@ -7,12 +7,38 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/betasve/go-commerce/services/auth/internal/data"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestModels returns an in-memory store whose clock starts at
+// 2025-03-26 15:04:05 UTC and advances by a second on every write. When seed
+// is true it already holds John Doe with id 1.
+func newTestModels(t *testing.T, seed bool) data.Models {
+	t.Helper()
+
+	clock := time.Date(2025, 3, 26, 15, 4, 5, 0, time.UTC)
+
+	users := data.NewMemoryUserModel()
+	users.Now = func() time.Time {
+		now := clock
+		clock = clock.Add(time.Second)
+		return now
+	}
+
+	if seed {
+		err := users.Insert(context.Background(), data.NewUser("John Doe", "test_email@example.com", "TestPassword321"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return data.Models{Users: users}
+}
+
 func TestCreateUserHandler(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -22,34 +48,71 @@ func TestCreateUserHandler(t *testing.T) {
 		expectedResponseBody string
 	}{
 		{"Error on empty request body", "", http.StatusBadRequest, "", `{"error":"the body must not be empty"}`},
-		{"Error on empty email in user body", `{"email":"","password": "pass123","name":"Test User"}`, http.StatusUnprocessableEntity, "", `{"error":{"email":"can't be blank"}}`},
-		{"Error on empty password in user body", `{"email":"test_email@example.com","password": "","name":"Test User"}`, http.StatusUnprocessableEntity, "", `{"error":{"password":"can't be blank"}}`},
-		{"Error on empty name in user body", `{"email":"test_email@example.com","password": "pass123","name":""}`, http.StatusUnprocessableEntity, "", `{"error":{"name":"can't be blank"}}`},
-		{"Successfully created the user", `{"email":"test_email@example.com","password": "pass123","name":"John Doe"}`, http.StatusCreated, "/v1/users/42", `{"user":{"id":42,"name":"John Doe","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}}`},
+		{"Error on empty email in user body", `{"email":"","password": "Password123","name":"Test User"}`, http.StatusUnprocessableEntity, "", `{"error":{"email":"can't be blank"}}`},
+		{"Error on empty password in user body", `{"email":"new_email@example.com","password": "","name":"Test User"}`, http.StatusUnprocessableEntity, "", `{"error":{"password":"can't be blank"}}`},
+		{"Error on empty name in user body", `{"email":"new_email@example.com","password": "Password123","name":""}`, http.StatusUnprocessableEntity, "", `{"error":{"name":"can't be blank"}}`},
+		{"Error on duplicate email", `{"email":"test_email@example.com","password": "Password123","name":"Jane Doe"}`, http.StatusUnprocessableEntity, "", `{"error":{"email":"a user with this email address already exists"}}`},
+		{"Successfully created the user", `{"email":"new_email@example.com","password": "Password123","name":"Jane Doe"}`, http.StatusCreated, "/v1/users/2", `{"user":{"id":2,"name":"Jane Doe","email":"new_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:06Z","updated_at":"2025-03-26T15:04:06Z"}}`},
 	}
 
 	for _, tc := range tests {
-		rr := httptest.NewRecorder()
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
 
-		app := application{
-			models: data.NewMockModels(),
-		}
+			app := application{
+				models: newTestModels(t, true),
+			}
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/test/url",
+				bytes.NewReader([]byte(tc.reqBody)),
+			)
 
-		req := httptest.NewRequest(
-			http.MethodPost,
-			"/test/url",
-			bytes.NewReader([]byte(tc.reqBody)),
-		)
-
-		app.createUserHandler(rr, req)
-
-		assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
-		assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"))
-		assert.Equal(
-			t,
-			tc.expectedResponseBody,
-			strings.TrimSpace(rr.Body.String()),
-		)
+			app.createUserHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"))
+			assert.Equal(
+				t,
+				tc.expectedResponseBody,
+				strings.TrimSpace(rr.Body.String()),
+			)
+		})
+	}
+}
+
+func TestListUsersHandler(t *testing.T) {
+	tests := []struct {
+		name                 string
+		query                string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{"Lists the users", "", http.StatusOK, `{"metadata":{"current_page":1,"page_size":20,"first_page":1,"last_page":1,"total_records":1},"users":[{"id":1,"name":"John Doe","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}]}`},
+		{"Filters out non matching users", "?name=jane", http.StatusOK, `{"metadata":{},"users":[]}`},
+		{"Error on invalid sort", "?sort=password", http.StatusUnprocessableEntity, `{"error":{"sort":"invalid sort value"}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			app := application{
+				models: newTestModels(t, true),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users"+tc.query, nil)
+
+			app.listUsersHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
+			assert.Equal(
+				t,
+				tc.expectedResponseBody,
+				strings.TrimSpace(rr.Body.String()),
+			)
+		})
 	}
 }
 
@@ -58,38 +121,43 @@ func TestShowUserHandler(t *testing.T) {
 		name                 string
 		userId               string
 		expectedStatusCode   int
+		expectedETag         string
 		expectedResponseBody string
 	}{
-		{"Error on invalid id", "abc", http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
-		{"Error on missing user id", "0", http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
-		{"Finds the user", "1", http.StatusOK, `{"user":{"id":42,"name":"John Doe","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}}`},
+		{"Error on invalid id", "abc", http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Error on missing user id", "0", http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Error on unknown user", "2", http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Finds the user", "1", http.StatusOK, `"1"`, `{"user":{"id":1,"name":"John Doe","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}}`},
 	}
 
 	for _, tc := range tests {
-		rr := httptest.NewRecorder()
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
 
-		app := application{
-			models: data.NewMockModels(),
-		}
+			app := application{
+				models: newTestModels(t, true),
+			}
 
-		req := httptest.NewRequest(
-			http.MethodGet,
-			"/test/url",
-			bytes.NewReader([]byte("")),
-		)
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/test/url",
+				bytes.NewReader([]byte("")),
+			)
 
-		params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
-		ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
-		req = req.WithContext(ctx)
+			params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
+			ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
+			req = req.WithContext(ctx)
 
-		app.showUserHandler(rr, req)
+			app.showUserHandler(rr, req)
 
-		assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
-		assert.Equal(
-			t,
-			tc.expectedResponseBody,
-			strings.TrimSpace(rr.Body.String()),
-		)
+			assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
+			assert.Equal(t, tc.expectedETag, rr.Header().Get("ETag"))
+			assert.Equal(
+				t,
+				tc.expectedResponseBody,
+				strings.TrimSpace(rr.Body.String()),
+			)
+		})
 	}
 }
 
@@ -97,41 +165,54 @@ func TestUpdateUserHandler(t *testing.T) {
 	tests := []struct {
 		name                 string
 		userId               string
+		ifMatch              string
 		userBody             string
 		expectedStatusCode   int
+		expectedETag         string
 		expectedResponseBody string
 	}{
-		{"Error on invalid id", "abc", `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
-		{"Error on missing user id", "0", `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
-		{"Updates the user", "1", `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusOK, `{"user":{"id":42,"name":"Johny Do","email":"test@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}}`},
-		{"Partially updates the user", "1", `{"name": "Johny Do"}`, http.StatusOK, `{"user":{"id":42,"name":"Johny Do","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:05Z"}}`},
+		{"Error on invalid id", "abc", `"1"`, `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Error on missing user id", "0", `"1"`, `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Error on unknown user", "2", `"1"`, `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusNotFound, "", `{"error":"the requested resource could not be found"}`},
+		{"Error on missing If-Match", "1", "", `{"name": "Johny Do"}`, http.StatusPreconditionRequired, "", `{"error":"this request must be conditional, please provide an If-Match header"}`},
+		{"Error on stale If-Match", "1", `"0"`, `{"name": "Johny Do"}`, http.StatusPreconditionFailed, `"1"`, `{"error":"the resource has been modified since it was last retrieved, please fetch it again"}`},
+		{"Error on invalid email", "1", `"1"`, `{"email":"not-an-email"}`, http.StatusUnprocessableEntity, "", `{"error":{"email":"does not look like a valid email"}}`},
+		{"Updates the user", "1", `"1"`, `{"name": "Johny Do","email":"test@example.com","password":"NewPass123"}`, http.StatusOK, `"2"`, `{"user":{"id":1,"name":"Johny Do","email":"test@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:06Z"}}`},
+		{"Partially updates the user", "1", `"1"`, `{"name": "Johny Do"}`, http.StatusOK, `"2"`, `{"user":{"id":1,"name":"Johny Do","email":"test_email@example.com","password":"[FILTERED]","created_at":"2025-03-26T15:04:05Z","updated_at":"2025-03-26T15:04:06Z"}}`},
 	}
 
 	for _, tc := range tests {
-		rr := httptest.NewRecorder()
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
 
-		app := application{
-			models: data.NewMockModels(),
-		}
+			app := application{
+				models: newTestModels(t, true),
+			}
 
-		req := httptest.NewRequest(
-			http.MethodPatch,
-			"/test/url",
-			bytes.NewReader([]byte(tc.userBody)),
-		)
+			req := httptest.NewRequest(
+				http.MethodPatch,
+				"/test/url",
+				bytes.NewReader([]byte(tc.userBody)),
+			)
 
-		params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
-		ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
-		req = req.WithContext(ctx)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
 
-		app.updateUserHandler(rr, req)
+			params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
+			ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
+			req = req.WithContext(ctx)
 
-		assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
-		assert.Equal(
-			t,
-			tc.expectedResponseBody,
-			strings.TrimSpace(rr.Body.String()),
-		)
+			app.updateUserHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
+			assert.Equal(t, tc.expectedETag, rr.Header().Get("ETag"))
+			assert.Equal(
+				t,
+				tc.expectedResponseBody,
+				strings.TrimSpace(rr.Body.String()),
+			)
+		})
 	}
 }
 
@@ -144,33 +225,36 @@ func TestDeleteUserHandler(t *testing.T) {
 	}{
 		{"Error on invalid id", "abc", http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
 		{"Error on missing user id", "0", http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
-		{"Updates the user", "1", http.StatusNoContent, `{}`},
+		{"Error on unknown user", "2", http.StatusNotFound, `{"error":"the requested resource could not be found"}`},
+		{"Deletes the user", "1", http.StatusNoContent, `{}`},
 	}
 
 	for _, tc := range tests {
-		rr := httptest.NewRecorder()
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
 
-		app := application{
-			models: data.NewMockModels(),
-		}
+			app := application{
+				models: newTestModels(t, true),
+			}
 
-		req := httptest.NewRequest(
-			http.MethodPut,
-			"/test/url",
-			bytes.NewReader([]byte("")),
-		)
+			req := httptest.NewRequest(
+				http.MethodDelete,
+				"/test/url",
+				bytes.NewReader([]byte("")),
+			)
 
-		params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
-		ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
-		req = req.WithContext(ctx)
+			params := httprouter.Params{httprouter.Param{Key: "id", Value: tc.userId}}
+			ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
+			req = req.WithContext(ctx)
 
-		app.deleteUserHandler(rr, req)
+			app.deleteUserHandler(rr, req)
 
-		assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
-		assert.Equal(
-			t,
-			tc.expectedResponseBody,
-			strings.TrimSpace(rr.Body.String()),
-		)
+			assert.Equal(t, tc.expectedStatusCode, rr.Result().StatusCode)
+			assert.Equal(
+				t,
+				tc.expectedResponseBody,
+				strings.TrimSpace(rr.Body.String()),
+			)
+		})
 	}
 }
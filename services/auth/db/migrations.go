@@ -0,0 +1,21 @@
+// Package db embeds the SQL migrations of the auth service into the binary.
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// Migrations returns the embedded migration files, rooted at the migrations
+// directory.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(files, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS users_roles (
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
@@ -0,0 +1,141 @@
+// Package background runs work that outlives the request that started it,
+// such as sending emails, and lets shutdown wait for it.
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+)
+
+// ErrStopped is returned by Go once Shutdown has been called.
+var ErrStopped = errors.New("background runner is shutting down")
+
+type task struct {
+	name    string
+	started time.Time
+}
+
+// Runner tracks running tasks. Their context is cancelled as soon as Shutdown
+// is called, so that loops such as periodic cleanups stop right away. Work
+// that should still complete, such as an email being sent, can ignore it
+// until the shutdown deadline.
+type Runner struct {
+	logger *jsonlog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	nextID  uint64
+	tasks   map[uint64]task
+	stopped bool
+}
+
+func New(logger *jsonlog.Logger) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Runner{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		tasks:  make(map[uint64]task),
+	}
+}
+
+// Go runs fn in a new goroutine. A panic in fn is logged rather than
+// crashing the process.
+func (r *Runner) Go(name string, fn func(ctx context.Context)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return ErrStopped
+	}
+
+	r.nextID++
+	id := r.nextID
+	r.tasks[id] = task{name: name, started: time.Now()}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				r.logger.PrintError(fmt.Errorf("%s", err), map[string]string{
+					"task": name,
+				})
+			}
+
+			r.mu.Lock()
+			delete(r.tasks, id)
+			r.mu.Unlock()
+		}()
+
+		fn(r.ctx)
+	}()
+
+	return nil
+}
+
+// Running returns the names of the tasks in flight, sorted.
+func (r *Runner) Running() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.tasks))
+	for _, t := range r.tasks {
+		names = append(names, t.name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Shutdown stops accepting tasks, cancels their context and waits for the
+// running ones until ctx is done. It then logs each one it is abandoning and
+// returns ctx's error.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	abandoned := make([]task, 0, len(r.tasks))
+	for _, t := range r.tasks {
+		abandoned = append(abandoned, t)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(abandoned, func(i, j int) bool { return abandoned[i].started.Before(abandoned[j].started) })
+
+	for _, t := range abandoned {
+		r.logger.PrintError(errors.New("abandoned background task"), map[string]string{
+			"task":    t.name,
+			"running": time.Since(t.started).Round(time.Millisecond).String(),
+		})
+	}
+
+	return ctx.Err()
+}
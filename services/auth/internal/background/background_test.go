@@ -0,0 +1,101 @@
+package background
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/jsonlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownWaitsForTasks(t *testing.T) {
+	r := New(jsonlog.New(&bytes.Buffer{}, jsonlog.LevelInfo))
+
+	var finished atomic.Bool
+	release := make(chan struct{})
+
+	err := r.Go("send welcome email", func(ctx context.Context) {
+		<-release
+		finished.Store(true)
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"send welcome email"}, r.Running())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	err = r.Shutdown(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, finished.Load())
+	assert.Empty(t, r.Running())
+
+	assert.ErrorIs(t, r.Go("too late", func(context.Context) {}), ErrStopped)
+}
+
+func TestShutdownCancelsTasks(t *testing.T) {
+	logs := &bytes.Buffer{}
+	r := New(jsonlog.New(logs, jsonlog.LevelInfo))
+
+	ticks := time.NewTicker(time.Millisecond)
+	defer ticks.Stop()
+
+	r.Go("expire sessions", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticks.C:
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := r.Shutdown(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, r.Running())
+	assert.NotContains(t, logs.String(), "abandoned background task")
+}
+
+func TestShutdownAbandonsTasks(t *testing.T) {
+	logs := &bytes.Buffer{}
+	r := New(jsonlog.New(logs, jsonlog.LevelInfo))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	r.Go("stuck export", func(ctx context.Context) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := r.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	assert.Contains(t, logs.String(), `"message":"abandoned background task"`)
+	assert.Contains(t, logs.String(), `"task":"stuck export"`)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	logs := &bytes.Buffer{}
+	r := New(jsonlog.New(logs, jsonlog.LevelInfo))
+
+	r.Go("broken", func(context.Context) { panic("boom") })
+
+	err := r.Shutdown(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, strings.Count(logs.String(), `"message":"boom"`))
+	assert.Contains(t, logs.String(), `"task":"broken"`)
+}
@@ -0,0 +1,352 @@
+// Package config layers settings over the flags a command defines. Each flag
+// takes its value from the first source that sets it, in this order: the
+// command line, the environment, a YAML or TOML file, and finally the flag's
+// own default.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources a Setting can come from.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is the effective value of a flag and where it came from. Values
+// of sensitive flags are redacted.
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type Options struct {
+	// EnvPrefix turns a flag name into its environment variable: db-dsn
+	// with GO_COMMERCE_ is GO_COMMERCE_DB_DSN. When the variable is unset,
+	// GO_COMMERCE_DB_DSN_FILE may name a file holding the value, which is
+	// how container orchestrators hand out secrets.
+	EnvPrefix string
+
+	// Aliases maps further environment variables to flag names, e.g. the
+	// DATABASE_URL set by docker-compose. The prefixed variable wins.
+	Aliases map[string]string
+
+	// FileFlag names the flag holding the path of the config file. It can
+	// be set on the command line or in the environment, not in the file.
+	FileFlag string
+
+	// IgnoreUnknown skips settings in the file that fs has no flag for,
+	// for commands that only use part of a file shared with others.
+	IgnoreUnknown bool
+
+	// Sensitive reports whether a flag's value must be redacted. It
+	// defaults to flags whose name mentions a DSN, URL, password, secret or
+	// token.
+	Sensitive func(name string) bool
+
+	// LookupEnv and ReadFile default to the os functions.
+	LookupEnv func(key string) (string, bool)
+	ReadFile  func(name string) ([]byte, error)
+}
+
+// Load parses args into fs, then fills in the flags they do not set from the
+// environment and the config file, with each value going through the flag's
+// own Set. Lists in the file set a flag once per item. It returns the
+// effective settings, sorted by name.
+func Load(fs *flag.FlagSet, args []string, opts Options) ([]Setting, error) {
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+	if opts.ReadFile == nil {
+		opts.ReadFile = os.ReadFile
+	}
+	if opts.Sensitive == nil {
+		opts.Sensitive = sensitive
+	}
+
+	recorders := make(map[string]*recordingValue)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		r := &recordingValue{Value: f.Value}
+		f.Value = r
+		recorders[f.Name] = r
+	})
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string)
+
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = SourceFlag
+	})
+
+	aliases := make(map[string][]string)
+	for env, name := range opts.Aliases {
+		aliases[name] = append(aliases[name], env)
+	}
+	for _, envs := range aliases {
+		sort.Strings(envs)
+	}
+
+	env := func(name string) (value, key string, ok bool, err error) {
+		key = opts.EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+		if value, ok := opts.LookupEnv(key); ok {
+			return value, key, true, nil
+		}
+
+		if path, ok := opts.LookupEnv(key + "_FILE"); ok {
+			b, err := opts.ReadFile(path)
+			if err != nil {
+				return "", key + "_FILE", false, err
+			}
+
+			return strings.TrimRight(string(b), "\r\n"), key + "_FILE", true, nil
+		}
+
+		for _, alias := range aliases[name] {
+			if value, ok := opts.LookupEnv(alias); ok {
+				return value, alias, true, nil
+			}
+		}
+
+		return "", "", false, nil
+	}
+
+	var file map[string][]string
+	var path string
+
+	if opts.FileFlag != "" {
+		if sources[opts.FileFlag] == "" {
+			value, key, ok, err := env(opts.FileFlag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+
+			if ok {
+				err = fs.Set(opts.FileFlag, value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+				}
+
+				sources[opts.FileFlag] = SourceEnv
+			}
+		}
+
+		if f := fs.Lookup(opts.FileFlag); f != nil {
+			path = f.Value.String()
+		}
+	}
+
+	if path != "" {
+		file, err = readFile(path, opts.ReadFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range file {
+			if name == opts.FileFlag {
+				return nil, fmt.Errorf("%s: %s cannot be set from the config file", path, name)
+			}
+
+			if fs.Lookup(name) == nil && !opts.IgnoreUnknown {
+				return nil, fmt.Errorf("%s: unknown setting %q", path, name)
+			}
+		}
+	}
+
+	var errs []error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if sources[f.Name] != "" {
+			return
+		}
+
+		value, key, ok, err := env(f.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+
+		if ok {
+			sources[f.Name] = SourceEnv
+
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, key, err))
+			}
+
+			return
+		}
+
+		if values, ok := file[f.Name]; ok {
+			sources[f.Name] = SourceFile
+
+			for _, value := range values {
+				if err := fs.Set(f.Name, value); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %w", path, value, f.Name, err))
+				}
+			}
+
+			return
+		}
+
+		sources[f.Name] = SourceDefault
+	})
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var settings []Setting
+
+	fs.VisitAll(func(f *flag.Flag) {
+		values := recorders[f.Name].values
+		if values == nil {
+			values = []string{f.DefValue}
+		}
+
+		if opts.Sensitive(f.Name) {
+			redacted := make([]string, len(values))
+			for i, value := range values {
+				redacted[i] = redact(value)
+			}
+			values = redacted
+		}
+
+		settings = append(settings, Setting{
+			Name:   f.Name,
+			Value:  strings.Join(values, ", "),
+			Source: sources[f.Name],
+		})
+	})
+
+	return settings, nil
+}
+
+// readFile reads a YAML or TOML config file, going by its extension, into
+// flag names and values. Nested tables join their keys with dashes, so
+// db: {dsn: ...} sets -db-dsn.
+func readFile(path string, read func(string) ([]byte, error)) (map[string][]string, error) {
+	b, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]any)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file, expected .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string][]string)
+	flatten("", raw, values)
+
+	return values, nil
+}
+
+func flatten(prefix string, raw map[string]any, values map[string][]string) {
+	for key, value := range raw {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(name, value, values)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = items
+		case nil:
+			values[name] = []string{""}
+		default:
+			values[name] = []string{fmt.Sprint(value)}
+		}
+	}
+}
+
+func sensitive(name string) bool {
+	for _, word := range []string{"dsn", "url", "password", "secret", "token"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact hides the credentials in value. URLs keep everything but the
+// password, anything else is hidden entirely.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	u, err := url.Parse(value)
+	if err == nil && u.Scheme != "" && u.Host != "" && !strings.Contains(strings.ToLower(u.RawQuery), "password") {
+		return u.Redacted()
+	}
+
+	return "[REDACTED]"
+}
+
+// recordingValue remembers the raw values a flag was set to, since Func
+// flags have no String of their own.
+type recordingValue struct {
+	flag.Value
+	values []string
+}
+
+func (v *recordingValue) Set(s string) error {
+	err := v.Value.Set(s)
+	if err != nil {
+		return err
+	}
+
+	v.values = append(v.values, s)
+
+	return nil
+}
+
+// String is called on a zero recordingValue by flag.PrintDefaults.
+func (v *recordingValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+
+	return v.Value.String()
+}
+
+func (v *recordingValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	port    int
+	dsn     string
+	debug   bool
+	timeout time.Duration
+	brokers []string
+}
+
+func newFlagSet(cfg *testConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.String("config", "", "config file")
+	fs.IntVar(&cfg.port, "port", 4000, "port")
+	fs.StringVar(&cfg.dsn, "db-dsn", "", "dsn")
+	fs.BoolVar(&cfg.debug, "debug", false, "debug")
+	fs.DurationVar(&cfg.timeout, "db-timeout", time.Second, "timeout")
+	fs.Func("brokers", "brokers", func(val string) error {
+		cfg.brokers = append(cfg.brokers, strings.Split(val, ",")...)
+		return nil
+	})
+
+	return fs
+}
+
+func testOptions(env map[string]string, files map[string]string) Options {
+	return Options{
+		EnvPrefix: "APP_",
+		Aliases:   map[string]string{"DATABASE_URL": "db-dsn"},
+		FileFlag:  "config",
+		LookupEnv: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		},
+		ReadFile: func(name string) ([]byte, error) {
+			content, ok := files[name]
+			if !ok {
+				return nil, fs.ErrNotExist
+			}
+			return []byte(content), nil
+		},
+	}
+}
+
+func source(settings []Setting, name string) Setting {
+	for _, s := range settings {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return Setting{}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	files := map[string]string{
+		"app.yaml": "port: 5000\ndebug: true\ndb:\n  timeout: 3s\nbrokers:\n  - a:9092\n  - b:9092\n",
+	}
+	env := map[string]string{
+		"APP_CONFIG":   "app.yaml",
+		"APP_DEBUG":    "false",
+		"DATABASE_URL": "postgres://user:secret@db/auth",
+	}
+
+	var cfg testConfig
+
+	settings, err := Load(newFlagSet(&cfg), []string{"-port", "6000"}, testOptions(env, files))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 6000, cfg.port)
+	assert.False(t, cfg.debug)
+	assert.Equal(t, "postgres://user:secret@db/auth", cfg.dsn)
+	assert.Equal(t, 3*time.Second, cfg.timeout)
+	assert.Equal(t, []string{"a:9092", "b:9092"}, cfg.brokers)
+
+	assert.Equal(t, []Setting{
+		{Name: "brokers", Value: "a:9092, b:9092", Source: SourceFile},
+		{Name: "config", Value: "app.yaml", Source: SourceEnv},
+		{Name: "db-dsn", Value: "postgres://user:xxxxx@db/auth", Source: SourceEnv},
+		{Name: "db-timeout", Value: "3s", Source: SourceFile},
+		{Name: "debug", Value: "false", Source: SourceEnv},
+		{Name: "port", Value: "6000", Source: SourceFlag},
+	}, settings)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	var cfg testConfig
+
+	settings, err := Load(newFlagSet(&cfg), nil, testOptions(nil, nil))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4000, cfg.port)
+	assert.Nil(t, cfg.brokers)
+
+	for _, s := range settings {
+		assert.Equal(t, SourceDefault, s.Source, s.Name)
+	}
+	assert.Equal(t, "1s", source(settings, "db-timeout").Value)
+}
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		files map[string]string
+		dsn   string
+		err   string
+	}{
+		{
+			name: "prefixed",
+			env:  map[string]string{"APP_DB_DSN": "postgres://a", "DATABASE_URL": "postgres://b"},
+			dsn:  "postgres://a",
+		},
+		{
+			name:  "file",
+			env:   map[string]string{"APP_DB_DSN_FILE": "/run/secrets/dsn", "DATABASE_URL": "postgres://b"},
+			files: map[string]string{"/run/secrets/dsn": "postgres://secret\n"},
+			dsn:   "postgres://secret",
+		},
+		{
+			name: "alias",
+			env:  map[string]string{"DATABASE_URL": "postgres://b"},
+			dsn:  "postgres://b",
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{"APP_DB_DSN_FILE": "/run/secrets/dsn"},
+			err:  "APP_DB_DSN_FILE: file does not exist",
+		},
+		{
+			name: "invalid value",
+			env:  map[string]string{"APP_PORT": "http"},
+			err:  `invalid value "http" for APP_PORT`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+
+			_, err := Load(newFlagSet(&cfg), nil, testOptions(tt.env, tt.files))
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.dsn, cfg.dsn)
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		port    int
+		timeout time.Duration
+		err     string
+	}{
+		{
+			name:    "yaml",
+			path:    "app.yml",
+			content: "port: 5000\ndb:\n  timeout: 2s\n",
+			port:    5000,
+			timeout: 2 * time.Second,
+		},
+		{
+			name:    "toml",
+			path:    "app.toml",
+			content: "port = 5000\n\n[db]\ntimeout = \"2s\"\n",
+			port:    5000,
+			timeout: 2 * time.Second,
+		},
+		{
+			name:    "unknown key",
+			path:    "app.yaml",
+			content: "prot: 5000\n",
+			err:     `app.yaml: unknown setting "prot"`,
+		},
+		{
+			name:    "config key",
+			path:    "app.yaml",
+			content: "config: other.yaml\n",
+			err:     "app.yaml: config cannot be set from the config file",
+		},
+		{
+			name:    "invalid value",
+			path:    "app.yaml",
+			content: "port: http\n",
+			err:     `app.yaml: invalid value "http" for port`,
+		},
+		{
+			name:    "unsupported extension",
+			path:    "app.json",
+			content: "{}",
+			err:     "app.json: unsupported config file",
+		},
+		{
+			name:    "malformed",
+			path:    "app.yaml",
+			content: "port: [",
+			err:     "app.yaml: yaml:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+
+			files := map[string]string{tt.path: tt.content}
+
+			_, err := Load(newFlagSet(&cfg), []string{"-config", tt.path}, testOptions(nil, files))
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.port, cfg.port)
+			assert.Equal(t, tt.timeout, cfg.timeout)
+		})
+	}
+}
+
+func TestLoadIgnoreUnknown(t *testing.T) {
+	var cfg testConfig
+
+	files := map[string]string{"app.yaml": "port: 5000\nlimiter:\n  enabled: false\n"}
+
+	opts := testOptions(nil, files)
+	opts.IgnoreUnknown = true
+
+	_, err := Load(newFlagSet(&cfg), []string{"-config", "app.yaml"}, opts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, cfg.port)
+}
+
+func TestLoadBadFlag(t *testing.T) {
+	var cfg testConfig
+
+	_, err := Load(newFlagSet(&cfg), []string{"-nope"}, testOptions(nil, nil))
+	assert.Error(t, err)
+}
+
+func TestLoadJoinsErrors(t *testing.T) {
+	var cfg testConfig
+
+	env := map[string]string{"APP_PORT": "http", "APP_DEBUG": "maybe"}
+
+	_, err := Load(newFlagSet(&cfg), nil, testOptions(env, nil))
+	assert.ErrorContains(t, err, "APP_PORT")
+	assert.ErrorContains(t, err, "APP_DEBUG")
+
+	var joined interface{ Unwrap() []error }
+	assert.True(t, errors.As(err, &joined))
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"postgres://user:secret@db:5432/auth?sslmode=disable", "postgres://user:xxxxx@db:5432/auth?sslmode=disable"},
+		{"redis://localhost:6379/0", "redis://localhost:6379/0"},
+		{"postgres://db/auth?user=u&password=secret", "[REDACTED]"},
+		{"host=db user=u password=secret", "[REDACTED]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.want, redact(tt.value))
+		})
+	}
+}
+
+func TestSensitive(t *testing.T) {
+	for name, want := range map[string]bool{
+		"db-dsn":            true,
+		"limiter-redis-url": true,
+		"jwt-secret":        true,
+		"port":              false,
+		"trace-exporter":    false,
+	} {
+		assert.Equal(t, want, sensitive(name), name)
+	}
+}
@@ -0,0 +1,568 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testUserModelContract runs the behaviour every Users implementation has to
+// share. newModels must return an empty store on each call.
+func testUserModelContract(t *testing.T, newModels func(t *testing.T) Models) {
+	ctx := context.Background()
+
+	t.Run("Insert assigns id and timestamps", func(t *testing.T) {
+		users := newModels(t).Users
+
+		user := NewUser("Jane Doe", "jane@example.com", "Password123")
+		if err := users.Insert(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+
+		if user.ID < 1 {
+			t.Errorf("Expected a positive id, got %d", user.ID)
+		}
+
+		if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+			t.Errorf("Expected timestamps to be set, got %v and %v", user.CreatedAt, user.UpdatedAt)
+		}
+
+		if user.Version != 1 {
+			t.Errorf("Expected version 1, got %d", user.Version)
+		}
+
+		stored, err := users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stored.Name != "Jane Doe" || stored.Email != "jane@example.com" {
+			t.Errorf("Expected the inserted user, got %+v", stored)
+		}
+
+		if !stored.UpdatedAt.Equal(user.UpdatedAt) {
+			t.Errorf("Expected updated_at %v, got %v", user.UpdatedAt, stored.UpdatedAt)
+		}
+
+		if ok, err := stored.Password.Matches("Password123"); err != nil || !ok {
+			t.Errorf("Expected the stored password to match, got %v (%v)", ok, err)
+		}
+	})
+
+	t.Run("Insert rejects duplicate emails", func(t *testing.T) {
+		users := newModels(t).Users
+
+		mustInsert(t, users, "Jane Doe", "jane@example.com")
+
+		err := users.Insert(ctx, NewUser("Jane Other", "jane@example.com", "Password123"))
+		if !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Expected %v, got %v", ErrDuplicateEmail, err)
+		}
+	})
+
+	t.Run("Get and GetByEmail report missing records", func(t *testing.T) {
+		users := newModels(t).Users
+
+		for _, id := range []int64{0, 1, 999} {
+			if _, err := users.Get(ctx, id); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("Expected %v for id %d, got %v", ErrRecordNotFound, id, err)
+			}
+		}
+
+		if _, err := users.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected %v, got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("GetByEmail finds the user", func(t *testing.T) {
+		users := newModels(t).Users
+
+		user := mustInsert(t, users, "Jane Doe", "jane@example.com")
+
+		found, err := users.GetByEmail(ctx, "jane@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found.ID != user.ID {
+			t.Errorf("Expected id %d, got %d", user.ID, found.ID)
+		}
+	})
+
+	t.Run("Update keeps the password unless it changes", func(t *testing.T) {
+		users := newModels(t).Users
+
+		user := mustInsert(t, users, "Jane Doe", "jane@example.com")
+
+		stored, err := users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored.Name = "Jane Smith"
+		if err := users.Update(ctx, stored); err != nil {
+			t.Fatal(err)
+		}
+
+		updated, err := users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if updated.Name != "Jane Smith" {
+			t.Errorf("Expected name %q, got %q", "Jane Smith", updated.Name)
+		}
+
+		if updated.Version != 2 || stored.Version != 2 {
+			t.Errorf("Expected version 2, got %d (stored) and %d (returned)", updated.Version, stored.Version)
+		}
+
+		if ok, _ := updated.Password.Matches("Password123"); !ok {
+			t.Error("Expected the original password to still match")
+		}
+
+		updated.Password.Set("NewPassword456")
+		if err := users.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		updated, err = users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, _ := updated.Password.Matches("NewPassword456"); !ok {
+			t.Error("Expected the new password to match")
+		}
+	})
+
+	t.Run("Update detects edit conflicts", func(t *testing.T) {
+		users := newModels(t).Users
+
+		user := mustInsert(t, users, "Jane Doe", "jane@example.com")
+
+		first, _ := users.Get(ctx, user.ID)
+		second, _ := users.Get(ctx, user.ID)
+
+		first.Name = "Jane First"
+		if err := users.Update(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+
+		second.Name = "Jane Second"
+		if err := users.Update(ctx, second); !errors.Is(err, ErrEditConflict) {
+			t.Errorf("Expected %v, got %v", ErrEditConflict, err)
+		}
+
+		missing := *first
+		missing.ID = 999
+		if err := users.Update(ctx, &missing); !errors.Is(err, ErrEditConflict) {
+			t.Errorf("Expected %v for a missing user, got %v", ErrEditConflict, err)
+		}
+	})
+
+	t.Run("Update rejects duplicate emails", func(t *testing.T) {
+		users := newModels(t).Users
+
+		mustInsert(t, users, "Jane Doe", "jane@example.com")
+		john := mustInsert(t, users, "John Doe", "john@example.com")
+
+		stored, _ := users.Get(ctx, john.ID)
+		stored.Email = "jane@example.com"
+
+		if err := users.Update(ctx, stored); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Expected %v, got %v", ErrDuplicateEmail, err)
+		}
+	})
+
+	t.Run("Delete removes the user", func(t *testing.T) {
+		users := newModels(t).Users
+
+		user := mustInsert(t, users, "Jane Doe", "jane@example.com")
+
+		if err := users.Delete(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := users.Get(ctx, user.ID); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected %v, got %v", ErrRecordNotFound, err)
+		}
+
+		if err := users.Delete(ctx, user.ID); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected %v, got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("Roles are replaced and dropped with the user", func(t *testing.T) {
+		models := newModels(t)
+
+		user := mustInsert(t, models.Users, "Jane Doe", "jane@example.com")
+
+		roles, err := models.Roles.GetAllForUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(roles) != 0 {
+			t.Errorf("Expected no roles, got %v", roles)
+		}
+
+		if err := models.Roles.SetForUser(ctx, user.ID, []string{RoleCustomer, RoleAdmin}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := models.Roles.SetForUser(ctx, user.ID, []string{RoleCustomer, RoleAdmin}); err != nil {
+			t.Fatal(err)
+		}
+
+		roles, _ = models.Roles.GetAllForUser(ctx, user.ID)
+		if len(roles) != 2 || roles[0] != RoleAdmin || roles[1] != RoleCustomer {
+			t.Errorf("Expected [admin customer], got %v", roles)
+		}
+
+		if err := models.Roles.SetForUser(ctx, user.ID, []string{RoleCustomer}); err != nil {
+			t.Fatal(err)
+		}
+
+		roles, _ = models.Roles.GetAllForUser(ctx, user.ID)
+		if len(roles) != 1 || roles[0] != RoleCustomer {
+			t.Errorf("Expected [customer], got %v", roles)
+		}
+
+		if err := models.Roles.SetForUser(ctx, 999, []string{RoleAdmin}); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected %v for a missing user, got %v", ErrRecordNotFound, err)
+		}
+
+		if err := models.Users.Delete(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		roles, _ = models.Roles.GetAllForUser(ctx, user.ID)
+		if len(roles) != 0 {
+			t.Errorf("Expected roles to be dropped with the user, got %v", roles)
+		}
+	})
+
+	t.Run("GetAll filters, sorts and paginates", func(t *testing.T) {
+		users := newModels(t).Users
+
+		mustInsert(t, users, "Alice Brown", "alice@example.com")
+		mustInsert(t, users, "Bob Brown", "bob@example.com")
+		mustInsert(t, users, "Carol White", "carol@example.com")
+		mustInsert(t, users, "Dave Brown", "dave@example.com")
+
+		safeList := []string{"id", "name", "-name"}
+
+		tests := []struct {
+			name          string
+			email         string
+			query         string
+			filters       Filters
+			expectedNames []string
+			expectedMeta  Metadata
+		}{
+			{"All users", "", "", Filters{1, 10, "id", safeList}, []string{"Alice Brown", "Bob Brown", "Carol White", "Dave Brown"}, Metadata{1, 10, 1, 1, 4}},
+			{"Name search", "", "brown", Filters{1, 10, "-name", safeList}, []string{"Dave Brown", "Bob Brown", "Alice Brown"}, Metadata{1, 10, 1, 1, 3}},
+			{"Multi word search", "", "Bob Brown", Filters{1, 10, "id", safeList}, []string{"Bob Brown"}, Metadata{1, 10, 1, 1, 1}},
+			{"Email ignores case", "CAROL@example.com", "", Filters{1, 10, "id", safeList}, []string{"Carol White"}, Metadata{1, 10, 1, 1, 1}},
+			{"Second page", "", "", Filters{2, 3, "name", safeList}, []string{"Dave Brown"}, Metadata{2, 3, 1, 2, 4}},
+			{"Past the last page", "", "", Filters{3, 3, "name", safeList}, []string{}, Metadata{}},
+			{"No matches", "", "green", Filters{1, 10, "id", safeList}, []string{}, Metadata{}},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				result, metadata, err := users.GetAll(ctx, tc.email, tc.query, tc.filters)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				names := []string{}
+				for _, u := range result {
+					names = append(names, u.Name)
+				}
+
+				if len(names) != len(tc.expectedNames) {
+					t.Fatalf("Expected %v, got %v", tc.expectedNames, names)
+				}
+
+				for i := range names {
+					if names[i] != tc.expectedNames[i] {
+						t.Fatalf("Expected %v, got %v", tc.expectedNames, names)
+					}
+				}
+
+				if metadata != tc.expectedMeta {
+					t.Errorf("Expected '%v', got '%v'", tc.expectedMeta, metadata)
+				}
+			})
+		}
+	})
+}
+
+// testIdempotencyModelContract runs the behaviour every Idempotency
+// implementation has to share. newModels must return an empty store on each
+// call.
+func testIdempotencyModelContract(t *testing.T, newModels func(t *testing.T) Models) {
+	ctx := context.Background()
+
+	newRequest := func() *IdempotentRequest {
+		return &IdempotentRequest{Scope: "ip:192.0.2.1 POST /v1/users", Key: "key-1", Fingerprint: "abc"}
+	}
+
+	t.Run("Claim records the first request", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		req := newRequest()
+		existing, err := store.Claim(ctx, req, time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing != nil {
+			t.Errorf("Expected no existing request, got %+v", existing)
+		}
+
+		if req.ExpiresAt.Before(time.Now().Add(59 * time.Minute)) {
+			t.Errorf("Expected the request to expire in an hour, got %v", req.ExpiresAt)
+		}
+	})
+
+	t.Run("Claim returns the request in flight", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		if _, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		retry := newRequest()
+		retry.Fingerprint = "other"
+
+		existing, err := store.Claim(ctx, retry, time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing == nil || !existing.InFlight() || existing.Fingerprint != "abc" {
+			t.Errorf("Expected the original request in flight, got %+v", existing)
+		}
+	})
+
+	t.Run("Claim returns the completed response", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		req := newRequest()
+		if _, err := store.Claim(ctx, req, time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		req.Status = 201
+		req.Header = map[string][]string{"Location": {"/v1/users/1"}}
+		req.Body = []byte(`{"user":{}}`)
+
+		if err := store.Complete(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+
+		existing, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing == nil || existing.Status != 201 || string(existing.Body) != `{"user":{}}` || existing.Header["Location"][0] != "/v1/users/1" {
+			t.Errorf("Expected the completed response, got %+v", existing)
+		}
+
+		if err := store.Complete(ctx, req); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected completing twice to fail with %v, got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("Complete needs the claimed fingerprint", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		if _, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		other := newRequest()
+		other.Fingerprint = "other"
+		other.Status = 201
+
+		if err := store.Complete(ctx, other); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected %v, got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("Release lets a retry claim the key", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		req := newRequest()
+		if _, err := store.Claim(ctx, req, time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Release(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+
+		existing, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing != nil {
+			t.Errorf("Expected the key to be free, got %+v", existing)
+		}
+	})
+
+	t.Run("Claim takes over a stale request in flight", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		if _, err := store.Claim(ctx, newRequest(), time.Millisecond, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		retry := newRequest()
+		existing, err := store.Claim(ctx, retry, time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing != nil {
+			t.Errorf("Expected the stale claim to be taken over, got %+v", existing)
+		}
+
+		if retry.LockedUntil.Before(time.Now().Add(59 * time.Second)) {
+			t.Errorf("Expected the new claim to be locked for a minute, got %v", retry.LockedUntil)
+		}
+	})
+
+	t.Run("A request that lost its claim leaves the retry's alone", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		stale := newRequest()
+		if _, err := store.Claim(ctx, stale, time.Millisecond, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		if _, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Release(ctx, stale); err != nil {
+			t.Fatal(err)
+		}
+
+		stale.Status = 201
+		if err := store.Complete(ctx, stale); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Expected completing a lost claim to fail with %v, got %v", ErrRecordNotFound, err)
+		}
+
+		existing, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing == nil || !existing.InFlight() {
+			t.Errorf("Expected the retry still in flight, got %+v", existing)
+		}
+	})
+
+	t.Run("Completed responses outlive the lock", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		req := newRequest()
+		if _, err := store.Claim(ctx, req, time.Millisecond, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		req.Status = 201
+		if err := store.Complete(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		existing, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing == nil || existing.Status != 201 {
+			t.Errorf("Expected the completed response, got %+v", existing)
+		}
+	})
+
+	t.Run("Keys are scoped", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		if _, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		other := newRequest()
+		other.Scope = "user:1 POST /v1/users"
+
+		existing, err := store.Claim(ctx, other, time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing != nil {
+			t.Errorf("Expected another scope to be free, got %+v", existing)
+		}
+	})
+
+	t.Run("Expired requests are forgotten", func(t *testing.T) {
+		store := newModels(t).Idempotency
+
+		if _, err := store.Claim(ctx, newRequest(), time.Millisecond, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		other := newRequest()
+		other.Key = "key-2"
+		if _, err := store.Claim(ctx, other, time.Millisecond, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		deleted, err := store.DeleteExpired(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted != 2 {
+			t.Errorf("Expected 2 expired requests, got %d", deleted)
+		}
+
+		existing, err := store.Claim(ctx, newRequest(), time.Minute, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if existing != nil {
+			t.Errorf("Expected the expired key to be free, got %+v", existing)
+		}
+	})
+}
+
+func mustInsert(t *testing.T, users interface {
+	Insert(context.Context, *User) error
+}, name, email string) *User {
+	t.Helper()
+
+	user := NewUser(name, email, "Password123")
+	if err := users.Insert(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
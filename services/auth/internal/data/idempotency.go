@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyInUse is returned by Claim when the key keeps changing
+// hands under it, as when several retries race to take over a stale claim.
+var ErrIdempotencyKeyInUse = errors.New("idempotency key in use")
+
+// IdempotentRequest is a request made with an Idempotency-Key and, once it
+// has completed, the response that retries of it replay.
+type IdempotentRequest struct {
+	// Scope keeps keys of different callers and routes apart.
+	Scope string
+	Key   string
+
+	// Fingerprint identifies the request's payload, so that a key reused
+	// for a different request can be told apart from a retry.
+	Fingerprint string
+
+	// Status is zero while the original request is in flight.
+	Status int
+	Header map[string][]string
+	Body   []byte
+
+	// LockedUntil bounds how long the original request may stay in flight.
+	// After it, a retry takes the key over, as the request that claimed it
+	// has most likely died with its server. Claim sets it, and Complete and
+	// Release need it unchanged, so that a request that lost its claim
+	// cannot touch the retry's.
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// InFlight reports whether the original request has not completed yet.
+func (r *IdempotentRequest) InFlight() bool {
+	return r.Status == 0
+}
+
+type IdempotencyModel struct {
+	DB *Router
+}
+
+// Claim records req as in flight for lock, and its response as kept for ttl.
+// When a request with the same scope and key has not expired yet, Claim
+// returns it instead and records nothing, unless it is still in flight past
+// its lock.
+func (m IdempotencyModel) Claim(ctx context.Context, req *IdempotentRequest, lock, ttl time.Duration) (*IdempotentRequest, error) {
+	claim := `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, locked_until, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4), NOW() + make_interval(secs => $5))
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = NULL, header = NULL, body = NULL,
+			created_at = NOW(), locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= NOW())
+		RETURNING locked_until, expires_at
+	`
+
+	existing := `
+		SELECT fingerprint, status, header, body, locked_until, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND expires_at > NOW()
+			AND (status IS NOT NULL OR locked_until > NOW())
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	db := m.DB.Writer(ctx)
+
+	// The existing request can expire, be released or lose its lock between
+	// the two statements, in which case claiming it again succeeds.
+	for attempt := 0; attempt < 3; attempt++ {
+		err := db.QueryRowContext(ctx, claim, req.Scope, req.Key, req.Fingerprint, lock.Seconds(), ttl.Seconds()).Scan(&req.LockedUntil, &req.ExpiresAt)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		stored := IdempotentRequest{Scope: req.Scope, Key: req.Key}
+
+		var (
+			status sql.NullInt64
+			header []byte
+		)
+
+		err = db.QueryRowContext(ctx, existing, req.Scope, req.Key).Scan(&stored.Fingerprint, &status, &header, &stored.Body, &stored.LockedUntil, &stored.ExpiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		stored.Status = int(status.Int64)
+
+		if header != nil {
+			err = json.Unmarshal(header, &stored.Header)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &stored, nil
+	}
+
+	return nil, ErrIdempotencyKeyInUse
+}
+
+// Complete stores the response of a request claimed with the same
+// fingerprint. It returns ErrRecordNotFound when the claim has expired or
+// been taken over.
+func (m IdempotencyModel) Complete(ctx context.Context, req *IdempotentRequest) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status = $4, header = $5, body = $6
+		WHERE scope = $1 AND key = $2 AND fingerprint = $3 AND status IS NULL AND expires_at > NOW()
+			AND locked_until = $7
+	`
+
+	header, err := json.Marshal(req.Header)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.Writer(ctx).ExecContext(ctx, query, req.Scope, req.Key, req.Fingerprint, req.Status, header, req.Body, req.LockedUntil)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Release forgets a request that is still in flight, so that a retry runs
+// it again. A claim that has been taken over is left to its new owner.
+func (m IdempotencyModel) Release(ctx context.Context, req *IdempotentRequest) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND status IS NULL AND locked_until = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Writer(ctx).ExecContext(ctx, query, req.Scope, req.Key, req.LockedUntil)
+
+	return err
+}
+
+// DeleteExpired removes expired requests and returns how many there were.
+// Claim already reuses expired keys, so this only reclaims space.
+func (m IdempotencyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.Writer(ctx).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryHook runs around every model method. It may return a derived context,
+// and the func it returns is called with the method's error once it is done.
+type QueryHook func(ctx context.Context, model, method string) (context.Context, func(err error))
+
+// QueryObserver is told how long each model method took and what it returned.
+type QueryObserver func(model, method string, duration time.Duration, err error)
+
+// Instrument returns a copy of m whose models run hooks around every call,
+// the first hook outermost.
+func (m Models) Instrument(hooks ...QueryHook) Models {
+	return Models{
+		Users:       instrumentedUsers{next: m.Users, hooks: hooks},
+		Roles:       instrumentedRoles{next: m.Roles, hooks: hooks},
+		Idempotency: instrumentedIdempotency{next: m.Idempotency, hooks: hooks},
+	}
+}
+
+// Observe adapts observe to a QueryHook that times each call. Missing
+// records are expected, so observe sees them as a nil error.
+func Observe(observe QueryObserver) QueryHook {
+	return func(ctx context.Context, model, method string) (context.Context, func(error)) {
+		start := time.Now()
+
+		return ctx, func(err error) {
+			if errors.Is(err, ErrRecordNotFound) {
+				err = nil
+			}
+
+			observe(model, method, time.Since(start), err)
+		}
+	}
+}
+
+// Trace returns a QueryHook that wraps each call in a span, with db.system
+// set to system, e.g. "postgresql". Missing records are expected, so they do
+// not mark the span as failed.
+func Trace(tracer trace.Tracer, system string) QueryHook {
+	return func(ctx context.Context, model, method string) (context.Context, func(error)) {
+		ctx, span := tracer.Start(ctx, model+"."+method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", system),
+				attribute.String("db.operation.name", method),
+				attribute.String("db.collection.name", model),
+			),
+		)
+
+		return ctx, func(err error) {
+			if err != nil && !errors.Is(err, ErrRecordNotFound) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			span.End()
+		}
+	}
+}
+
+// run calls every hook for one model method and returns the context to call
+// the method with, and a func to defer with a pointer to its error.
+func run(ctx context.Context, hooks []QueryHook, model, method string) (context.Context, func(err *error)) {
+	done := make([]func(error), len(hooks))
+
+	for i, hook := range hooks {
+		ctx, done[i] = hook(ctx, model, method)
+	}
+
+	return ctx, func(err *error) {
+		for i := len(done) - 1; i >= 0; i-- {
+			done[i](*err)
+		}
+	}
+}
+
+type instrumentedUsers struct {
+	next  UserStore
+	hooks []QueryHook
+}
+
+func (u instrumentedUsers) Insert(ctx context.Context, user *User) (err error) {
+	ctx, done := run(ctx, u.hooks, "users", "Insert")
+	defer done(&err)
+
+	return u.next.Insert(ctx, user)
+}
+
+func (u instrumentedUsers) Get(ctx context.Context, id int64) (_ *User, err error) {
+	ctx, done := run(ctx, u.hooks, "users", "Get")
+	defer done(&err)
+
+	return u.next.Get(ctx, id)
+}
+
+func (u instrumentedUsers) GetByEmail(ctx context.Context, email string) (_ *User, err error) {
+	ctx, done := run(ctx, u.hooks, "users", "GetByEmail")
+	defer done(&err)
+
+	return u.next.GetByEmail(ctx, email)
+}
+
+func (u instrumentedUsers) GetAll(ctx context.Context, email, name string, filters Filters) (_ []*User, _ Metadata, err error) {
+	ctx, done := run(ctx, u.hooks, "users", "GetAll")
+	defer done(&err)
+
+	return u.next.GetAll(ctx, email, name, filters)
+}
+
+func (u instrumentedUsers) Update(ctx context.Context, user *User) (err error) {
+	ctx, done := run(ctx, u.hooks, "users", "Update")
+	defer done(&err)
+
+	return u.next.Update(ctx, user)
+}
+
+func (u instrumentedUsers) Delete(ctx context.Context, id int64) (err error) {
+	ctx, done := run(ctx, u.hooks, "users", "Delete")
+	defer done(&err)
+
+	return u.next.Delete(ctx, id)
+}
+
+type instrumentedRoles struct {
+	next  RoleStore
+	hooks []QueryHook
+}
+
+func (r instrumentedRoles) GetAllForUser(ctx context.Context, userID int64) (_ []string, err error) {
+	ctx, done := run(ctx, r.hooks, "roles", "GetAllForUser")
+	defer done(&err)
+
+	return r.next.GetAllForUser(ctx, userID)
+}
+
+func (r instrumentedRoles) SetForUser(ctx context.Context, userID int64, roles []string) (err error) {
+	ctx, done := run(ctx, r.hooks, "roles", "SetForUser")
+	defer done(&err)
+
+	return r.next.SetForUser(ctx, userID, roles)
+}
+
+type instrumentedIdempotency struct {
+	next  IdempotencyStore
+	hooks []QueryHook
+}
+
+func (i instrumentedIdempotency) Claim(ctx context.Context, req *IdempotentRequest, lock, ttl time.Duration) (_ *IdempotentRequest, err error) {
+	ctx, done := run(ctx, i.hooks, "idempotency_keys", "Claim")
+	defer done(&err)
+
+	return i.next.Claim(ctx, req, lock, ttl)
+}
+
+func (i instrumentedIdempotency) Complete(ctx context.Context, req *IdempotentRequest) (err error) {
+	ctx, done := run(ctx, i.hooks, "idempotency_keys", "Complete")
+	defer done(&err)
+
+	return i.next.Complete(ctx, req)
+}
+
+func (i instrumentedIdempotency) Release(ctx context.Context, req *IdempotentRequest) (err error) {
+	ctx, done := run(ctx, i.hooks, "idempotency_keys", "Release")
+	defer done(&err)
+
+	return i.next.Release(ctx, req)
+}
+
+func (i instrumentedIdempotency) DeleteExpired(ctx context.Context) (_ int64, err error) {
+	ctx, done := run(ctx, i.hooks, "idempotency_keys", "DeleteExpired")
+	defer done(&err)
+
+	return i.next.DeleteExpired(ctx)
+}
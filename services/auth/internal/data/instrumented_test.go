@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrument(t *testing.T) {
+	type call struct {
+		model, method string
+		err           error
+	}
+
+	var calls []call
+
+	models := NewMemoryModels().Instrument(Observe(func(model, method string, duration time.Duration, err error) {
+		assert.GreaterOrEqual(t, duration, time.Duration(0))
+		calls = append(calls, call{model, method, err})
+	}))
+
+	ctx := context.Background()
+	user := NewUser("Jane Doe", "jane@example.com", "Password123!")
+
+	assert.NoError(t, models.Users.Insert(ctx, user))
+	assert.NoError(t, models.Roles.SetForUser(ctx, user.ID, []string{RoleCustomer}))
+
+	_, err := models.Users.Get(ctx, 42)
+	assert.True(t, errors.Is(err, ErrRecordNotFound))
+
+	assert.Equal(t, []call{
+		{"users", "Insert", nil},
+		{"roles", "SetForUser", nil},
+		{"users", "Get", nil},
+	}, calls)
+}
+
+func TestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	models := NewMemoryModels().Instrument(Trace(provider.Tracer("test"), "memory"))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+
+	_, err := models.Users.Get(ctx, 42)
+	assert.True(t, errors.Is(err, ErrRecordNotFound))
+
+	err = models.Users.Update(ctx, &User{ID: 42, Name: "Jane Doe", Email: "jane@example.com"})
+	assert.True(t, errors.Is(err, ErrEditConflict))
+
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+
+	assert.Equal(t, "users.Get", spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, attribute.String("db.system", "memory"))
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+
+	assert.Equal(t, "users.Update", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}
@@ -0,0 +1,374 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryUserModel is a thread-safe, in-memory implementation of the Users
+// model. It mirrors the semantics of UserModel (unique emails, optimistic
+// locking on the version, filtering, sorting and pagination) and is meant
+// for tests and local development without a PostgreSQL instance.
+type MemoryUserModel struct {
+	// Now returns the current time. It can be replaced in tests to get
+	// deterministic timestamps.
+	Now func() time.Time
+
+	mu     sync.RWMutex
+	users  map[int64]*User
+	roles  map[int64][]string
+	lastID int64
+}
+
+func NewMemoryUserModel() *MemoryUserModel {
+	return &MemoryUserModel{
+		Now:   time.Now,
+		users: make(map[int64]*User),
+		roles: make(map[int64][]string),
+	}
+}
+
+func (m *MemoryUserModel) Insert(ctx context.Context, user *User) error {
+	err := user.Password.Set(*user.Password.plaintext)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	now := m.now()
+
+	m.lastID++
+	user.ID = m.lastID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+
+	m.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+func (m *MemoryUserModel) Get(ctx context.Context, id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (m *MemoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return copyUser(user), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m *MemoryUserModel) GetAll(ctx context.Context, email, name string, filters Filters) ([]*User, Metadata, error) {
+	column := filters.sortColumn()
+	desc := filters.sortDirection() == "DESC"
+
+	m.mu.RLock()
+
+	matched := []*User{}
+	for _, user := range m.users {
+		if email != "" && !strings.EqualFold(user.Email, email) {
+			continue
+		}
+
+		if name != "" && !matchesWords(user.Name, name) {
+			continue
+		}
+
+		matched = append(matched, copyUser(user))
+	}
+
+	m.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareUsers(matched[i], matched[j], column)
+		if c == 0 {
+			return matched[i].ID < matched[j].ID
+		}
+
+		if desc {
+			return c > 0
+		}
+
+		return c < 0
+	})
+
+	start := min(filters.offset(), len(matched))
+	end := min(start+filters.limit(), len(matched))
+	users := matched[start:end]
+
+	// PostgreSQL reports the total via count(*) OVER(), which yields no rows
+	// at all once the offset is past the end, so mirror that here.
+	totalRecords := 0
+	if len(users) > 0 {
+		totalRecords = len(matched)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
+
+func (m *MemoryUserModel) Update(ctx context.Context, user *User) error {
+	if user.Password.plaintext != nil {
+		err := user.Password.Set(*user.Password.plaintext)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	if m.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	user.CreatedAt = stored.CreatedAt
+	user.UpdatedAt = m.now()
+	user.Version = stored.Version + 1
+
+	m.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+func (m *MemoryUserModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.users, id)
+	delete(m.roles, id)
+
+	return nil
+}
+
+// MemoryRoleModel keeps roles next to the users of a MemoryUserModel, so that
+// deleting a user drops its roles like the foreign key does in PostgreSQL.
+type MemoryRoleModel struct {
+	Users *MemoryUserModel
+}
+
+func (m MemoryRoleModel) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	m.Users.mu.RLock()
+	defer m.Users.mu.RUnlock()
+
+	return append([]string{}, m.Users.roles[userID]...), nil
+}
+
+func (m MemoryRoleModel) SetForUser(ctx context.Context, userID int64, roles []string) error {
+	m.Users.mu.Lock()
+	defer m.Users.mu.Unlock()
+
+	if _, ok := m.Users.users[userID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	m.Users.roles[userID] = sortedRoles(roles)
+
+	return nil
+}
+
+// now truncates to microseconds, the resolution PostgreSQL stores timestamps
+// with, so that round-tripped values compare equal in both models.
+func (m *MemoryUserModel) now() time.Time {
+	return m.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (m *MemoryUserModel) emailTaken(email string, exceptID int64) bool {
+	for id, user := range m.users {
+		if id != exceptID && user.Email == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+func copyUser(u *User) *User {
+	c := *u
+	c.Password = password{hash: append([]byte{}, u.Password.hash...)}
+
+	return &c
+}
+
+func compareUsers(a, b *User, column string) int {
+	switch column {
+	case "id":
+		return cmp.Compare(a.ID, b.ID)
+	case "email":
+		return strings.Compare(a.Email, b.Email)
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		panic("unsupported sort column: " + column)
+	}
+}
+
+// matchesWords approximates to_tsvector('simple', name) @@
+// plainto_tsquery('simple', query): every word of the query has to be present
+// in the name, ignoring case.
+func matchesWords(name, query string) bool {
+	words := make(map[string]bool)
+	for _, w := range splitWords(name) {
+		words[w] = true
+	}
+
+	for _, w := range splitWords(query) {
+		if !words[w] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// MemoryIdempotencyModel keeps idempotent requests in memory, dropping
+// expired ones as new requests are claimed.
+type MemoryIdempotencyModel struct {
+	// Now returns the current time. It can be replaced in tests.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	requests map[[2]string]*IdempotentRequest
+}
+
+func NewMemoryIdempotencyModel() *MemoryIdempotencyModel {
+	return &MemoryIdempotencyModel{
+		Now:      time.Now,
+		requests: make(map[[2]string]*IdempotentRequest),
+	}
+}
+
+func (m *MemoryIdempotencyModel) Claim(ctx context.Context, req *IdempotentRequest, lock, ttl time.Duration) (*IdempotentRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	m.deleteExpired(now)
+
+	if stored, ok := m.requests[[2]string{req.Scope, req.Key}]; ok && (!stored.InFlight() || now.Before(stored.LockedUntil)) {
+		return copyIdempotentRequest(stored), nil
+	}
+
+	req.Status, req.Header, req.Body = 0, nil, nil
+	req.LockedUntil = now.Add(lock)
+	req.ExpiresAt = now.Add(ttl)
+	m.requests[[2]string{req.Scope, req.Key}] = copyIdempotentRequest(req)
+
+	return nil, nil
+}
+
+func (m *MemoryIdempotencyModel) Complete(ctx context.Context, req *IdempotentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.requests[[2]string{req.Scope, req.Key}]
+	if !ok || stored.Fingerprint != req.Fingerprint || !stored.InFlight() || !m.Now().Before(stored.ExpiresAt) || !stored.LockedUntil.Equal(req.LockedUntil) {
+		return ErrRecordNotFound
+	}
+
+	completed := copyIdempotentRequest(req)
+	completed.LockedUntil = stored.LockedUntil
+	completed.ExpiresAt = stored.ExpiresAt
+	m.requests[[2]string{req.Scope, req.Key}] = completed
+
+	return nil
+}
+
+func (m *MemoryIdempotencyModel) Release(ctx context.Context, req *IdempotentRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.requests[[2]string{req.Scope, req.Key}]; ok && stored.InFlight() && stored.LockedUntil.Equal(req.LockedUntil) {
+		delete(m.requests, [2]string{req.Scope, req.Key})
+	}
+
+	return nil
+}
+
+func (m *MemoryIdempotencyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteExpired(m.Now()), nil
+}
+
+func (m *MemoryIdempotencyModel) deleteExpired(now time.Time) int64 {
+	var deleted int64
+
+	for id, stored := range m.requests {
+		if !now.Before(stored.ExpiresAt) {
+			delete(m.requests, id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+func copyIdempotentRequest(req *IdempotentRequest) *IdempotentRequest {
+	c := *req
+	c.Body = append([]byte(nil), req.Body...)
+
+	if req.Header != nil {
+		c.Header = make(map[string][]string, len(req.Header))
+		for key, values := range req.Header {
+			c.Header[key] = append([]string(nil), values...)
+		}
+	}
+
+	return &c
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryUserModelContract(t *testing.T) {
+	testUserModelContract(t, func(t *testing.T) Models {
+		return NewMemoryModels()
+	})
+}
+
+func TestMemoryIdempotencyModelContract(t *testing.T) {
+	testIdempotencyModelContract(t, func(t *testing.T) Models {
+		return NewMemoryModels()
+	})
+}
+
+func TestMemoryUserModelConcurrentInserts(t *testing.T) {
+	users := NewMemoryUserModel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- users.Insert(context.Background(), NewUser("Jane Doe", "jane@example.com", "Password123"))
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	inserted := 0
+	for err := range errs {
+		if err == nil {
+			inserted++
+		}
+	}
+
+	if inserted != 1 {
+		t.Errorf("Expected exactly one insert to succeed, got %d", inserted)
+	}
+}
@@ -1,8 +1,9 @@
 package data
 
 import (
-	"database/sql"
+	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,24 +11,49 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	Get(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetAll(ctx context.Context, email, name string, filters Filters) ([]*User, Metadata, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type RoleStore interface {
+	GetAllForUser(ctx context.Context, userID int64) ([]string, error)
+	SetForUser(ctx context.Context, userID int64, roles []string) error
+}
+
+// IdempotencyStore keeps requests made with an Idempotency-Key and their
+// responses until they expire.
+type IdempotencyStore interface {
+	Claim(ctx context.Context, req *IdempotentRequest, lock, ttl time.Duration) (*IdempotentRequest, error)
+	Complete(ctx context.Context, req *IdempotentRequest) error
+	Release(ctx context.Context, req *IdempotentRequest) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
 type Models struct {
-	Users interface {
-		Insert(user *User) error
-		Get(id int64) (*User, error)
-		GetAll(email, name string, filters Filters) ([]*User, Metadata, error)
-		Update(user *User) error
-		Delete(id int64) error
-	}
+	Users       UserStore
+	Roles       RoleStore
+	Idempotency IdempotencyStore
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db *Router) Models {
 	return Models{
-		Users: UserModel{DB: db},
+		Users:       UserModel{DB: db},
+		Roles:       RoleModel{DB: db},
+		Idempotency: IdempotencyModel{DB: db},
 	}
 }
 
-func NewMockModels() Models {
+func NewMemoryModels() Models {
+	users := NewMemoryUserModel()
+
 	return Models{
-		Users: MockUserModel{},
+		Users:       users,
+		Roles:       MemoryRoleModel{Users: users},
+		Idempotency: NewMemoryIdempotencyModel(),
 	}
 }
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	RoleAdmin    = "admin"
+	RoleCustomer = "customer"
+)
+
+var Roles = []string{RoleAdmin, RoleCustomer}
+
+type RoleModel struct {
+	DB *Router
+}
+
+// GetAllForUser returns the user's roles sorted by name. A user without roles,
+// or one that does not exist, has none.
+func (m RoleModel) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	query := `
+		SELECT role
+		FROM users_roles
+		WHERE user_id = $1
+		ORDER BY role
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.Reader(ctx).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := []string{}
+
+	for rows.Next() {
+		var role string
+
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// SetForUser replaces the user's roles with the given ones.
+func (m RoleModel) SetForUser(ctx context.Context, userID int64, roles []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.Writer(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO users_roles (user_id, role)
+		SELECT $1, unnest($2::text[])
+	`
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(roles))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ValidateRoles(v *validator.Validator, roles []string) {
+	v.Check(validator.Unique(roles), "roles", "must not contain duplicate values")
+
+	for _, role := range roles {
+		v.Check(validator.In(role, Roles...), "roles", "contains an unknown role")
+	}
+}
+
+func sortedRoles(roles []string) []string {
+	sorted := slices.Clone(roles)
+	slices.Sort(sorted)
+
+	return sorted
+}
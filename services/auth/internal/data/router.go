@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites marks ctx so that, once a write has gone through the
+// Router with it, every later read on the same context is served by the
+// primary instead of a possibly lagging replica.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, new(atomic.Bool))
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that every read through the Router with it is
+// served by the primary, e.g. a read whose result a write is based on.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Router sends writes to the primary and spreads reads across the healthy
+// replicas, falling back to the primary when none of them is available.
+type Router struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// NewRouter checks the replicas once. CheckReplicas keeps checking them.
+func NewRouter(primary *sql.DB, replicas []*sql.DB) *Router {
+	r := &Router{primary: primary}
+
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+
+	r.checkReplicas(context.Background())
+
+	return r
+}
+
+// CheckReplicas checks the replicas every interval until ctx is done, so
+// that reads move off a replica that goes down and back once it recovers.
+// It returns at once when there are no replicas.
+func (r *Router) CheckReplicas(ctx context.Context, interval time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkReplicas(ctx)
+		}
+	}
+}
+
+func (r *Router) Primary() *sql.DB {
+	return r.primary
+}
+
+// Reader returns the pool a read-only query should use.
+func (r *Router) Reader(ctx context.Context) *sql.DB {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return r.primary
+	}
+
+	if wrote, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool); ok && wrote.Load() {
+		return r.primary
+	}
+
+	n := len(r.replicas)
+	start := int(r.next.Add(1) % uint64(max(n, 1)))
+
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+
+	return r.primary
+}
+
+// Writer returns the primary and records the write on ctx for
+// WithReadYourWrites.
+func (r *Router) Writer(ctx context.Context) *sql.DB {
+	if wrote, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool); ok {
+		wrote.Store(true)
+	}
+
+	return r.primary
+}
+
+// Healthy reports how many replicas passed their last health check.
+func (r *Router) Healthy() (healthy, total int) {
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy++
+		}
+	}
+
+	return healthy, len(r.replicas)
+}
+
+// Stats returns the connection pool statistics of the primary and of every
+// replica, keyed by role.
+func (r *Router) Stats() map[string]sql.DBStats {
+	stats := map[string]sql.DBStats{"primary": r.primary.Stats()}
+
+	for i, rep := range r.replicas {
+		stats[fmt.Sprintf("replica_%d", i)] = rep.db.Stats()
+	}
+
+	return stats
+}
+
+func (r *Router) checkReplicas(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+
+			rep.healthy.Store(rep.db.PingContext(ctx) == nil)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Close closes every pool. CheckReplicas must have returned by then.
+func (r *Router) Close() error {
+	errs := []error{r.primary.Close()}
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,211 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pingDriver is a database/sql driver whose connections only answer pings.
+// Pings to a DSN listed in down fail.
+type pingDriver struct {
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+func (d *pingDriver) Open(name string) (driver.Conn, error) {
+	return &pingConn{driver: d, name: name}, nil
+}
+
+func (d *pingDriver) setDown(name string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.down[name] = down
+}
+
+type pingConn struct {
+	driver *pingDriver
+	name   string
+}
+
+func (c *pingConn) Ping(ctx context.Context) error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+
+	if c.driver.down[c.name] {
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
+func (c *pingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *pingConn) Close() error { return nil }
+
+func (c *pingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+var testPingDriver = &pingDriver{down: make(map[string]bool)}
+
+func init() {
+	sql.Register("ping", testPingDriver)
+}
+
+func openPingDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("ping", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every check has to dial again so that setDown takes effect.
+	db.SetMaxIdleConns(0)
+
+	return db
+}
+
+func TestRouterReader(t *testing.T) {
+	primary := openPingDB(t, "primary")
+	replicaA := openPingDB(t, "router-a")
+	replicaB := openPingDB(t, "router-b")
+
+	testPingDriver.setDown("router-b", true)
+	t.Cleanup(func() { testPingDriver.setDown("router-b", false) })
+
+	router := NewRouter(primary, []*sql.DB{replicaA, replicaB})
+	defer router.Close()
+
+	if healthy, total := router.Healthy(); healthy != 1 || total != 2 {
+		t.Fatalf("Expected 1 of 2 healthy replicas, got %d of %d", healthy, total)
+	}
+
+	for i := 0; i < 4; i++ {
+		if db := router.Reader(context.Background()); db != replicaA {
+			t.Errorf("Expected reads to go to the healthy replica")
+		}
+	}
+
+	if db := router.Writer(context.Background()); db != primary {
+		t.Errorf("Expected writes to go to the primary")
+	}
+}
+
+func TestRouterFallsBackToPrimary(t *testing.T) {
+	primary := openPingDB(t, "primary")
+	replica := openPingDB(t, "router-fallback")
+
+	testPingDriver.setDown("router-fallback", true)
+	t.Cleanup(func() { testPingDriver.setDown("router-fallback", false) })
+
+	router := NewRouter(primary, []*sql.DB{replica})
+	defer router.Close()
+
+	if db := router.Reader(context.Background()); db != primary {
+		t.Errorf("Expected reads to fall back to the primary")
+	}
+
+	testPingDriver.setDown("router-fallback", false)
+	router.checkReplicas(context.Background())
+
+	if db := router.Reader(context.Background()); db != replica {
+		t.Errorf("Expected reads to return to the replica once it recovers")
+	}
+}
+
+func TestRouterCheckReplicas(t *testing.T) {
+	primary := openPingDB(t, "primary")
+	replica := openPingDB(t, "router-check")
+
+	router := NewRouter(primary, []*sql.DB{replica})
+	defer router.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		router.CheckReplicas(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	testPingDriver.setDown("router-check", true)
+	t.Cleanup(func() { testPingDriver.setDown("router-check", false) })
+
+	deadline := time.Now().Add(time.Second)
+	for router.Reader(context.Background()) != primary {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected reads to move to the primary once the replica is down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected CheckReplicas to return once its context is done")
+	}
+}
+
+func TestRouterReadYourWrites(t *testing.T) {
+	primary := openPingDB(t, "primary")
+	replica := openPingDB(t, "router-ryw")
+
+	router := NewRouter(primary, []*sql.DB{replica})
+	defer router.Close()
+
+	ctx := WithReadYourWrites(context.Background())
+
+	if db := router.Reader(ctx); db != replica {
+		t.Errorf("Expected reads before a write to go to the replica")
+	}
+
+	router.Writer(ctx)
+
+	if db := router.Reader(ctx); db != primary {
+		t.Errorf("Expected reads after a write to go to the primary")
+	}
+
+	if db := router.Reader(context.Background()); db != replica {
+		t.Errorf("Expected other contexts to keep using the replica")
+	}
+}
+
+func TestRouterWithPrimary(t *testing.T) {
+	primary := openPingDB(t, "primary")
+	replica := openPingDB(t, "router-with-primary")
+
+	router := NewRouter(primary, []*sql.DB{replica})
+	defer router.Close()
+
+	ctx := WithPrimary(context.Background())
+
+	if db := router.Reader(ctx); db != primary {
+		t.Errorf("Expected reads marked for the primary to go to the primary")
+	}
+
+	if db := router.Reader(context.Background()); db != replica {
+		t.Errorf("Expected other contexts to keep using the replica")
+	}
+}
+
+func TestRouterWithoutReplicas(t *testing.T) {
+	primary := openPingDB(t, "primary")
+
+	router := NewRouter(primary, nil)
+	defer router.Close()
+
+	if db := router.Reader(context.Background()); db != primary {
+		t.Errorf("Expected reads to go to the primary")
+	}
+}
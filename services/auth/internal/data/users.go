@@ -22,6 +22,7 @@ type User struct {
 	Password  password  `json:"password"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"-"`
 }
 
 type password struct {
@@ -41,6 +42,10 @@ func (p *password) Set(plaintextPassword string) error {
 	return nil
 }
 
+func (p password) MarshalJSON() ([]byte, error) {
+	return []byte(`"[FILTERED]"`), nil
+}
+
 func (p *password) Matches(plaintextPassword string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
@@ -56,7 +61,7 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB *Router
 }
 
 func NewUser(name, email, pwd string) *User {
@@ -68,11 +73,11 @@ func NewUser(name, email, pwd string) *User {
 
 }
 
-func (u UserModel) Insert(user *User) error {
+func (u UserModel) Insert(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (name, email, password)
 		VALUES ($1, $2, $3)
-		RETURNING id, created_at
+		RETURNING id, created_at, updated_at, version
 	`
 
 	err := user.Password.Set(*user.Password.plaintext)
@@ -86,10 +91,10 @@ func (u UserModel) Insert(user *User) error {
 		user.Password.hash,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err = u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt)
+	err = u.DB.Writer(ctx).QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -102,28 +107,30 @@ func (u UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (u UserModel) Get(id int64) (*User, error) {
+func (u UserModel) Get(ctx context.Context, id int64) (*User, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT id, created_at, updated_at, name, email
+		SELECT id, created_at, updated_at, name, email, password, version
 		FROM users
 		WHERE id = $1
 	`
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err := u.DB.QueryRowContext(ctx, query, id).Scan(
+	err := u.DB.Reader(ctx).QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Name,
 		&user.Email,
+		&user.Password.hash,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -138,25 +145,26 @@ func (u UserModel) Get(id int64) (*User, error) {
 	return &user, nil
 }
 
-func (u UserModel) GetByEmail(email string) (*User, error) {
+func (u UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, created_at, updated_at, name, email, password
+		SELECT id, created_at, updated_at, name, email, password, version
 		FROM users
 		WHERE email = $1
 	`
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err := u.DB.QueryRowContext(ctx, query, email).Scan(
+	err := u.DB.Reader(ctx).QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Name,
 		&user.Email,
 		&user.Password.hash,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -171,19 +179,19 @@ func (u UserModel) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func (u UserModel) GetAll(email, name string, filters Filters) ([]*User, Metadata, error) {
+func (u UserModel) GetAll(ctx context.Context, email, name string, filters Filters) ([]*User, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, email, name, created_at, updated_at
+		SELECT count(*) OVER(), id, email, name, created_at, updated_at, version
 		FROM users
 		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
 		AND (LOWER(email) = LOWER($2) OR $2 = '')
 		ORDER BY %s %s, id ASC
 		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	rows, err := u.DB.QueryContext(ctx, query, name, email, filters.limit(), filters.offset())
+	rows, err := u.DB.Reader(ctx).QueryContext(ctx, query, name, email, filters.limit(), filters.offset())
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -203,6 +211,7 @@ func (u UserModel) GetAll(email, name string, filters Filters) ([]*User, Metadat
 			&user.Name,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.Version,
 		)
 
 		if err != nil {
@@ -221,16 +230,18 @@ func (u UserModel) GetAll(email, name string, filters Filters) ([]*User, Metadat
 	return users, metadata, nil
 }
 
-func (u UserModel) Update(user *User) error {
+func (u UserModel) Update(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
-		SET name = $1, email = $2, password = $3
-		WHERE id = $4 AND updated_at = $5
-		RETURNING updated_at
+		SET name = $1, email = $2, password = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version
 	`
-	err := user.Password.Set(*user.Password.plaintext)
-	if err != nil {
-		return err
+	if user.Password.plaintext != nil {
+		err := user.Password.Set(*user.Password.plaintext)
+		if err != nil {
+			return err
+		}
 	}
 
 	args := []interface{}{
@@ -238,13 +249,13 @@ func (u UserModel) Update(user *User) error {
 		user.Email,
 		user.Password.hash,
 		user.ID,
-		user.UpdatedAt,
+		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err = u.DB.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
+	err := u.DB.Writer(ctx).QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -259,7 +270,7 @@ func (u UserModel) Update(user *User) error {
 	return nil
 }
 
-func (u UserModel) Delete(id int64) error {
+func (u UserModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -269,10 +280,10 @@ func (u UserModel) Delete(id int64) error {
 		WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	result, err := u.DB.ExecContext(ctx, query, id)
+	result, err := u.DB.Writer(ctx).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -289,63 +300,6 @@ func (u UserModel) Delete(id int64) error {
 	return nil
 }
 
-type MockUserModel struct {
-	DB *sql.DB
-}
-
-func (u MockUserModel) Insert(user *User) error {
-	user.ID = 42
-	user.Name = "John Doe"
-	user.Email = "test_email@example.com"
-
-	t, err := time.Parse("2006-01-02 15:04:05", "2025-03-26 15:04:05")
-	if err != nil {
-		return err
-	}
-	user.CreatedAt = t
-	user.UpdatedAt = t
-
-	return nil
-}
-
-func (u MockUserModel) Get(id int64) (*User, error) {
-	user := &User{}
-	user.ID = 42
-	user.Name = "John Doe"
-	user.Email = "test_email@example.com"
-	plaintextPassword := "TestPassword321"
-	user.Password = password{&plaintextPassword, []byte{}}
-
-	t, err := time.Parse("2006-01-02 15:04:05", "2025-03-26 15:04:05")
-	if err != nil {
-		return nil, err
-	}
-	user.CreatedAt = t
-	user.UpdatedAt = t
-
-	return user, nil
-}
-
-func (u MockUserModel) GetAll(email, name string, filters Filters) ([]*User, Metadata, error) {
-	t, err := time.Parse("2006-01-02 15:04:05", "2025-03-26 15:04:05")
-	if err != nil {
-		return nil, Metadata{}, err
-	}
-
-	return []*User{
-		{ID: 1, Email: "test@example.com", Name: "John Doe", CreatedAt: t, UpdatedAt: t},
-		{ID: 1, Email: "test2@example.com", Name: "Jill Doe", CreatedAt: t, UpdatedAt: t},
-	}, Metadata{1, 20, 1, 2, 40}, nil
-}
-
-func (u MockUserModel) Update(user *User) error {
-	return nil
-}
-
-func (u MockUserModel) Delete(id int64) error {
-	return nil
-}
-
 func ValidateEmail(v *validator.Validator, email string) {
 	v.Check(email != "", "email", "can't be blank")
 	v.Check(
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/betasve/go-commerce/services/auth/db"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq"
+)
+
+// TestUserModelContract runs the shared contracts against PostgreSQL. It needs
+// a disposable database in GO_COMMERCE_TEST_DB_DSN and is skipped otherwise.
+func TestUserModelContract(t *testing.T) {
+	dsn := os.Getenv("GO_COMMERCE_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GO_COMMERCE_TEST_DB_DSN is not set")
+	}
+
+	pool, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	driver, err := postgres.WithInstance(pool, &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := iofs.New(db.Migrations(), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrator.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+
+	newModels := func(t *testing.T) Models {
+		_, err := pool.Exec("TRUNCATE users, idempotency_keys RESTART IDENTITY CASCADE")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return NewModels(NewRouter(pool, nil))
+	}
+
+	testUserModelContract(t, newModels)
+	testIdempotencyModelContract(t, newModels)
+}
@@ -0,0 +1,239 @@
+// Package health runs the dependency checks behind the readiness probe.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// CheckFunc returns nil when the dependency is usable. It must honour ctx.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+type Component struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string               `json:"status"`
+	CheckedAt  time.Time            `json:"checked_at"`
+	Components map[string]Component `json:"components"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+// WithoutErrors returns a copy of r without the errors of its components,
+// which can name hosts, users and schema versions best kept private.
+func (r Report) WithoutErrors() Report {
+	components := make(map[string]Component, len(r.Components))
+	for name, c := range r.Components {
+		c.Error = ""
+		components[name] = c
+	}
+
+	r.Components = components
+
+	return r
+}
+
+// Checker runs every registered check concurrently and caches the report, so
+// that frequent probes from several load balancers do not hammer the
+// dependencies.
+type Checker struct {
+	CacheTTL time.Duration
+	Now      func() time.Time
+
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	checks []check
+	cached *Report
+}
+
+func NewChecker(cacheTTL time.Duration) *Checker {
+	return &Checker{
+		CacheTTL: cacheTTL,
+		Now:      time.Now,
+	}
+}
+
+// Register adds a check that fails when fn does not return within timeout.
+func (c *Checker) Register(name string, timeout time.Duration, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn})
+	c.cached = nil
+}
+
+// Drain makes every later report not ready, whatever the checks say, so that
+// load balancers stop routing to an instance that is shutting down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Names returns the registered check names, sorted.
+func (c *Checker) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.checks))
+	for _, ch := range c.checks {
+		names = append(names, ch.name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Check returns the cached report, or runs the checks when it is older than
+// CacheTTL. Concurrent callers wait for a single run. The report is shared,
+// so the checks ignore the cancellation of ctx and are only bounded by their
+// own timeouts: a probe that hangs up must not cache every check as down.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Now()
+
+	if c.cached == nil || now.Sub(c.cached.CheckedAt) >= c.CacheTTL {
+		report := c.run(context.WithoutCancel(ctx), now)
+		c.cached = &report
+	}
+
+	report := *c.cached
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, now time.Time) Report {
+	report := Report{
+		Status:     StatusReady,
+		CheckedAt:  now,
+		Components: make(map[string]Component, len(c.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			component := runCheck(ctx, ch)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Components[ch.name] = component
+			if component.Status != StatusUp {
+				report.Status = StatusNotReady
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func runCheck(ctx context.Context, ch check) (component Component) {
+	ctx, cancel := context.WithTimeout(ctx, ch.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	defer func() {
+		component.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	// The check runs separately so that one ignoring ctx still times out.
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				result <- fmt.Errorf("panic: %v", err)
+			}
+		}()
+
+		result <- ch.fn(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return Component{Status: StatusDown, Error: err.Error()}
+		}
+
+		return Component{Status: StatusUp}
+	case <-ctx.Done():
+		return Component{Status: StatusDown, Error: fmt.Sprintf("timed out after %s", ch.timeout)}
+	}
+}
+
+// TCP checks that addr accepts connections, e.g. a Kafka broker.
+func TCP(addr string) CheckFunc {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
+
+// HTTP checks that a GET of url succeeds, e.g. a JWKS endpoint.
+func HTTP(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode >= 300 {
+			return errors.New(res.Status)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	c := NewChecker(time.Minute)
+
+	c.Register("database", time.Second, func(context.Context) error { return nil })
+	c.Register("kafka", time.Second, func(context.Context) error { return errors.New("connection refused") })
+	c.Register("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.Register("stuck", 10*time.Millisecond, func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	c.Register("broken", time.Second, func(context.Context) error { panic("boom") })
+
+	report := c.Check(context.Background())
+
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.False(t, report.Ready())
+	assert.Equal(t, []string{"broken", "database", "kafka", "slow", "stuck"}, c.Names())
+
+	assert.Equal(t, Component{Status: StatusUp}, withoutLatency(report.Components["database"]))
+	assert.Equal(t, Component{Status: StatusDown, Error: "connection refused"}, withoutLatency(report.Components["kafka"]))
+	assert.Equal(t, Component{Status: StatusDown, Error: "timed out after 10ms"}, withoutLatency(report.Components["slow"]))
+	assert.Equal(t, Component{Status: StatusDown, Error: "timed out after 10ms"}, withoutLatency(report.Components["stuck"]))
+	assert.Equal(t, Component{Status: StatusDown, Error: "panic: boom"}, withoutLatency(report.Components["broken"]))
+	assert.Less(t, report.Components["stuck"].LatencyMS, 500.0)
+
+	public := report.WithoutErrors()
+	assert.Equal(t, Component{Status: StatusDown}, withoutLatency(public.Components["kafka"]))
+	assert.Equal(t, "connection refused", report.Components["kafka"].Error, "the original report is left as it is")
+}
+
+func withoutLatency(c Component) Component {
+	c.LatencyMS = 0
+	return c
+}
+
+func TestCheckCaches(t *testing.T) {
+	now := time.Date(2025, 3, 26, 15, 4, 5, 0, time.UTC)
+
+	c := NewChecker(time.Second)
+	c.Now = func() time.Time { return now }
+
+	var runs atomic.Int32
+	c.Register("database", time.Second, func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+
+	c.Check(context.Background())
+	c.Check(context.Background())
+	assert.Equal(t, int32(1), runs.Load())
+
+	now = now.Add(time.Second)
+	c.Check(context.Background())
+	assert.Equal(t, int32(2), runs.Load())
+}
+
+func TestCheckIgnoresCallerCancellation(t *testing.T) {
+	c := NewChecker(time.Minute)
+	c.Register("database", time.Second, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+			return nil
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.True(t, c.Check(ctx).Ready())
+	assert.True(t, c.Check(context.Background()).Ready())
+}
+
+func TestDrain(t *testing.T) {
+	c := NewChecker(time.Minute)
+	c.Register("database", time.Second, func(context.Context) error { return nil })
+
+	assert.True(t, c.Check(context.Background()).Ready())
+
+	c.Drain()
+
+	report := c.Check(context.Background())
+	assert.Equal(t, StatusDraining, report.Status)
+	assert.Equal(t, StatusUp, report.Components["database"].Status)
+}
+
+func TestTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := l.Addr().String()
+
+	assert.NoError(t, TCP(addr)(context.Background()))
+
+	l.Close()
+
+	assert.Error(t, TCP(addr)(context.Background()))
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/jwks.json" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, HTTP(srv.Client(), srv.URL+"/.well-known/jwks.json")(context.Background()))
+	assert.EqualError(t, HTTP(srv.Client(), srv.URL+"/missing")(context.Background()), "404 Not Found")
+}
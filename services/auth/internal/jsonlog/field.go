@@ -0,0 +1,104 @@
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Field is a typed property of a log entry. Numbers and booleans keep their
+// JSON type, so that log queries can compare them without parsing strings.
+type Field struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Field {
+	return Field{key, value}
+}
+
+func Int(key string, value int) Field {
+	return Field{key, value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{key, value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{key, value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{key, value}
+}
+
+// Duration is logged the way time.Duration prints, e.g. 1.5s.
+func Duration(key string, value time.Duration) Field {
+	return Field{key, value.String()}
+}
+
+// Time is logged in UTC as RFC 3339 with nanoseconds.
+func Time(key string, value time.Time) Field {
+	return Field{key, value.UTC().Format(time.RFC3339Nano)}
+}
+
+// Err logs the message of err under "error", or null when err is nil.
+func Err(err error) Field {
+	if err == nil {
+		return Field{"error", nil}
+	}
+
+	return Field{"error", err.Error()}
+}
+
+// Object nests fields under key.
+func Object(key string, fields ...Field) Field {
+	return Field{key, properties(nil, fields)}
+}
+
+// Any logs value as encoding/json would, apart from errors, durations, times
+// and fmt.Stringers, which are logged as strings. A value that cannot be
+// marshalled is logged with fmt's %v rather than spoiling the entry.
+func Any(key string, value any) Field {
+	switch v := value.(type) {
+	case error:
+		return Field{key, v.Error()}
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case fmt.Stringer:
+		return Field{key, v.String()}
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return Field{key, fmt.Sprintf("%v", value)}
+	}
+
+	return Field{key, json.RawMessage(b)}
+}
+
+// properties merges field lists into the properties of an entry, later keys
+// winning, or returns nil when there are none.
+func properties(lists ...[]Field) map[string]any {
+	n := 0
+	for _, fields := range lists {
+		n += len(fields)
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	props := make(map[string]any, n)
+
+	for _, fields := range lists {
+		for _, f := range fields {
+			props[f.Key] = f.Value
+		}
+	}
+
+	return props
+}
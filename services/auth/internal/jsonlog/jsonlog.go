@@ -2,34 +2,45 @@ package jsonlog
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Level int8
 
+// LevelInfo is the zero value, so that a Level left unset logs what it used
+// to before DEBUG and WARN existed.
 const (
-	LevelInfo Level = iota
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
 	LevelError
 	LevelFatal
 	LevelOff
 )
 
 type logBody struct {
-	Level      string            `json:"level"`
-	Time       string            `json:"time"`
-	Message    string            `json:"message"`
-	Properties map[string]string `json:"properties,omitempty"`
-	Trace      string            `json:"trace,omitempty"`
+	Level      string         `json:"level"`
+	Time       string         `json:"time,omitempty"`
+	Message    string         `json:"message"`
+	Properties map[string]any `json:"properties,omitempty"`
+	Trace      string         `json:"trace,omitempty"`
 }
 
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
@@ -41,21 +52,128 @@ func (l Level) String() string {
 	}
 }
 
+// ParseLevel accepts the names String returns, in any case.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = level
+
+	return nil
+}
+
+// core is what a Logger shares with the loggers derived from it through
+// With, so that changing the level of one changes them all.
+type core struct {
+	out        io.Writer
+	minLevel   atomic.Int32
+	traceLevel atomic.Int32
+	redactor   atomic.Pointer[Redactor]
+	mu         sync.Mutex
+	exitFn     func(code int)
+}
+
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	mu       sync.Mutex
-	exitFn   func(code int)
+	*core
+	fields []Field
 }
 
+// New returns a logger writing entries at minLevel or above to out. Entries
+// at LevelError or above carry a stack trace until SetTraceLevel says
+// otherwise, and everything goes through DefaultRedactor until SetRedactor
+// says otherwise.
 func New(out io.Writer, minLevel Level) *Logger {
+	c := &core{
+		out:    out,
+		exitFn: os.Exit,
+	}
+	c.minLevel.Store(int32(minLevel))
+	c.traceLevel.Store(int32(LevelError))
+	c.redactor.Store(DefaultRedactor())
+
+	return &Logger{core: c}
+}
+
+// Level returns the minimum level logged.
+func (l *Logger) Level() Level {
+	return Level(l.minLevel.Load())
+}
+
+// SetLevel changes the minimum level logged, for this logger and every
+// logger sharing its output. It is safe to call while logging.
+func (l *Logger) SetLevel(level Level) {
+	l.minLevel.Store(int32(level))
+}
+
+// TraceLevel returns the level from which entries carry a stack trace.
+func (l *Logger) TraceLevel() Level {
+	return Level(l.traceLevel.Load())
+}
+
+// SetTraceLevel attaches stack traces to entries at level or above.
+// LevelOff leaves them out entirely.
+func (l *Logger) SetTraceLevel(level Level) {
+	l.traceLevel.Store(int32(level))
+}
+
+// SetRedactor changes how entries are redacted. A nil Redactor turns
+// redaction off.
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.redactor.Store(r)
+}
+
+// Enabled reports whether entries at level are logged, so that callers can
+// skip building expensive fields.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// With returns a logger that adds fields to every entry, after the fields of
+// l. Fields given to an entry win over those with the same key.
+func (l *Logger) With(fields ...Field) *Logger {
 	return &Logger{
-		out:      out,
-		minLevel: minLevel,
-		exitFn:   os.Exit,
+		core:   l.core,
+		fields: append(l.fields[:len(l.fields):len(l.fields)], fields...),
 	}
 }
 
+func (l *Logger) Debug(message string, fields ...Field) {
+	l.log(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Field) {
+	l.log(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...Field) {
+	l.log(LevelWarn, message, fields)
+}
+
+func (l *Logger) Error(err error, fields ...Field) {
+	l.log(LevelError, err.Error(), fields)
+}
+
+func (l *Logger) Fatal(err error, fields ...Field) {
+	l.log(LevelFatal, err.Error(), fields)
+	l.exitFn(1)
+}
+
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
 	l.print(LevelInfo, message, properties)
 }
@@ -70,18 +188,41 @@ func (l *Logger) PrintFatal(err error, properties map[string]string) {
 }
 
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
-	if level < l.minLevel {
+	fields := make([]Field, 0, len(properties))
+	for key, value := range properties {
+		fields = append(fields, String(key, value))
+	}
+
+	return l.log(level, message, fields)
+}
+
+func (l *Logger) log(level Level, message string, fields []Field) (int, error) {
+	return l.logAt(time.Now(), level, message, fields)
+}
+
+// logAt logs an entry that happened at t, leaving the time out when t is
+// zero.
+func (l *Logger) logAt(t time.Time, level Level, message string, fields []Field) (int, error) {
+	if !l.Enabled(level) {
 		return 0, nil
 	}
 
 	aux := &logBody{
 		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
 		Message:    message,
-		Properties: properties,
+		Properties: properties(l.fields, fields),
+	}
+
+	if !t.IsZero() {
+		aux.Time = t.UTC().Format(time.RFC3339)
+	}
+
+	if r := l.redactor.Load(); r != nil {
+		aux.Message = r.String(aux.Message)
+		aux.Properties = r.properties(aux.Properties)
 	}
 
-	if level >= LevelError {
+	if level >= l.TraceLevel() {
 		aux.Trace = string(debug.Stack())
 	}
 
@@ -98,6 +239,8 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 	return l.out.Write(append(line, '\n'))
 }
 
+// Write logs message at ERROR, so that the Logger can back a *log.Logger.
+// LogLogger makes one that logs at another level.
 func (l *Logger) Write(message []byte) (n int, err error) {
 	return l.print(LevelError, string(message), nil)
 }
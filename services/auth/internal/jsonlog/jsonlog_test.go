@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestString(t *testing.T) {
@@ -14,7 +18,9 @@ func TestString(t *testing.T) {
 		level         Level
 		expectedLevel string
 	}{
+		{"Debug level", LevelDebug, "DEBUG"},
 		{"Info level", LevelInfo, "INFO"},
+		{"Warn level", LevelWarn, "WARN"},
 		{"Error level", LevelError, "ERROR"},
 		{"Fatal level", LevelFatal, "FATAL"},
 		{"Off", LevelOff, "OFF"},
@@ -44,8 +50,8 @@ func TestNewLogger(t *testing.T) {
 		t.Errorf("expected out to be %v, got %v", &buf, logger.out)
 	}
 
-	if logger.minLevel != expectedLevel {
-		t.Errorf("expected minLevel to be %v, got %v", expectedLevel, logger.minLevel)
+	if logger.Level() != expectedLevel {
+		t.Errorf("expected minLevel to be %v, got %v", expectedLevel, logger.Level())
 	}
 }
 
@@ -92,11 +98,8 @@ func TestPrintFatal(t *testing.T) {
 	var buf bytes.Buffer
 	var payload logBody
 	var exitCalled bool
-	log := &Logger{
-		out:      &buf,
-		minLevel: LevelFatal,
-		exitFn:   func(code int) { exitCalled = true },
-	}
+	log := New(&buf, LevelFatal)
+	log.exitFn = func(code int) { exitCalled = true }
 
 	log.PrintFatal(errors.New("some fatal error"), map[string]string{"key": "fatal error"})
 
@@ -219,3 +222,172 @@ func TestPrint(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLevel(t *testing.T) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		for _, s := range []string{l.String(), strings.ToLower(l.String())} {
+			level, err := ParseLevel(s)
+			assert.NoError(t, err)
+			assert.Equal(t, l, level)
+		}
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.EqualError(t, err, `unknown log level "verbose"`)
+
+	var level Level
+	assert.NoError(t, level.UnmarshalText([]byte("warn")))
+	assert.Equal(t, LevelWarn, level)
+
+	text, err := LevelDebug.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG", string(text))
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []logBody {
+	t.Helper()
+
+	var lines []logBody
+
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line logBody
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(&buf, LevelWarn)
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error(errors.New("error"))
+
+	logger.SetLevel(LevelDebug)
+	logger.Debug("debug again")
+
+	var messages []string
+	for _, line := range decodeLines(t, &buf) {
+		messages = append(messages, line.Level+" "+line.Message)
+	}
+
+	assert.Equal(t, []string{"WARN warn", "ERROR error", "DEBUG debug again"}, messages)
+	assert.True(t, logger.Enabled(LevelDebug))
+}
+
+func TestFields(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(&buf, LevelInfo)
+
+	logger.Info("typed",
+		String("name", "alice"),
+		Int("status", 201),
+		Int64("user_id", 42),
+		Float64("ratio", 0.5),
+		Bool("slow", true),
+		Duration("duration", 1500*time.Millisecond),
+		Time("at", time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))),
+		Err(errors.New("boom")),
+		Object("db", Int("open", 3), Object("wait", Duration("total", time.Second))),
+		Any("roles", []string{"admin"}),
+		Any("level", LevelWarn),
+		Any("channel", make(chan int)),
+	)
+
+	var line struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	expected := map[string]string{
+		"name":     `"alice"`,
+		"status":   `201`,
+		"user_id":  `42`,
+		"ratio":    `0.5`,
+		"slow":     `true`,
+		"duration": `"1.5s"`,
+		"at":       `"2024-05-01T10:00:00Z"`,
+		"error":    `"boom"`,
+		"db":       `{"open":3,"wait":{"total":"1s"}}`,
+		"roles":    `["admin"]`,
+		"level":    `"WARN"`,
+	}
+
+	for key, value := range expected {
+		assert.JSONEq(t, value, string(line.Properties[key]), key)
+	}
+
+	assert.True(t, strings.HasPrefix(string(line.Properties["channel"]), `"0x`))
+	assert.Equal(t, Field{"error", nil}, Err(nil))
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(&buf, LevelInfo)
+	request := logger.With(String("request_id", "abc"), String("route", "/v1/users"))
+	user := request.With(Int64("user_id", 7))
+
+	// Sibling children must not share the parent's backing array.
+	other := request.With(Int64("user_id", 8))
+
+	user.Info("first", String("route", "/v1/users/:id"))
+	other.Info("second")
+	logger.Info("third")
+
+	// The level is shared, whichever logger changes it.
+	user.SetLevel(LevelError)
+	logger.Info("dropped")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+
+	assert.Equal(t, map[string]any{"request_id": "abc", "route": "/v1/users/:id", "user_id": float64(7)}, lines[0].Properties)
+	assert.Equal(t, map[string]any{"request_id": "abc", "route": "/v1/users", "user_id": float64(8)}, lines[1].Properties)
+	assert.Nil(t, lines[2].Properties)
+	assert.Equal(t, LevelError, logger.Level())
+}
+
+func TestTraceLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		traceLevel Level
+		level      Level
+		traced     bool
+	}{
+		{"Errors are traced by default", LevelError, LevelError, true},
+		{"Warnings are not traced by default", LevelError, LevelWarn, false},
+		{"Fatal only", LevelFatal, LevelError, false},
+		{"Off", LevelOff, LevelFatal, false},
+		{"Warnings traced on request", LevelWarn, LevelWarn, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			logger := New(&buf, LevelDebug)
+			logger.exitFn = func(int) {}
+			logger.SetTraceLevel(tc.traceLevel)
+
+			_, err := logger.log(tc.level, "message", nil)
+			assert.NoError(t, err)
+
+			lines := decodeLines(t, &buf)
+			assert.Equal(t, tc.traced, lines[0].Trace != "")
+		})
+	}
+
+	assert.Equal(t, LevelError, New(io.Discard, LevelInfo).TraceLevel())
+}
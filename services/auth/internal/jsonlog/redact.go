@@ -0,0 +1,326 @@
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces sensitive values in log entries.
+const Redacted = "[REDACTED]"
+
+// DefaultKeys are the patterns, matched case-insensitively anywhere in a
+// property key or URL query parameter name, whose values are always
+// redacted.
+var DefaultKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie",
+	"session", "credential", `api_?key`, `private_?key`, "dsn", "email", "card",
+}
+
+// Detector finds sensitive values inside strings, whatever their key, such
+// as an email address in an error message.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+
+	// Find, if set, picks the sensitive parts out of each match, as start
+	// and end offsets into it, e.g. the card numbers in a run of numbers.
+	// The parts are replaced with Redacted rather than Replacement.
+	Find func(match string) [][2]int
+
+	// Replacement is expanded as by regexp.Expand, so it can keep part of
+	// the match. It defaults to Redacted.
+	Replacement string
+}
+
+// Detectors are the detectors available, in the order they run. Earlier
+// ones take out what would confuse later ones, such as the password in a
+// DSN, which looks like the start of an email address.
+var Detectors = []Detector{
+	{
+		Name:        "url-credentials",
+		Pattern:     regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://[^:/?#\s@]*:)[^@/?#\s]+@`),
+		Replacement: "${1}" + Redacted + "@",
+	},
+	{
+		Name:        "bearer",
+		Pattern:     regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`),
+		Replacement: "$1 " + Redacted,
+	},
+	{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		// The pattern takes in whole words, joined by single spaces after
+		// digits, so that cardNumbers sees the full UUID or ID a run of
+		// digits belongs to, and the amounts or order numbers logged next
+		// to a card.
+		Name:    "card",
+		Pattern: regexp.MustCompile(`[\w-]*\d[\w-]*(?: \d[\w-]*)*`),
+		Find:    cardNumbers,
+	},
+}
+
+var cardGroups = regexp.MustCompile(`^\d(?:[ -]?\d){12,18}$`)
+
+// LookupDetector returns the detector in Detectors called name.
+func LookupDetector(name string) (Detector, bool) {
+	for _, d := range Detectors {
+		if d.Name == name {
+			return d, true
+		}
+	}
+
+	return Detector{}, false
+}
+
+// queryParam matches name=value pairs in query strings and in key/value
+// DSNs such as "host=db password=secret".
+var queryParam = regexp.MustCompile(`(^|[?&;\s])([A-Za-z0-9_.\[\]-]+)=([^&;\s#]*)`)
+
+// Redactor removes sensitive data from log entries before they are
+// marshalled: the values of properties and query parameters whose keys
+// match a pattern, and whatever the detectors find in strings, including
+// the message.
+type Redactor struct {
+	keys      *regexp.Regexp
+	detectors []Detector
+}
+
+// NewRedactor compiles the key patterns, which are regular expressions and
+// must not be empty. With no keys, only the detectors apply.
+func NewRedactor(keys []string, detectors []Detector) (*Redactor, error) {
+	r := &Redactor{detectors: detectors}
+
+	if len(keys) > 0 {
+		alternatives := make([]string, len(keys))
+
+		for i, key := range keys {
+			// An empty pattern would match, and so redact, every key.
+			if key == "" {
+				return nil, errors.New("empty redaction key pattern")
+			}
+
+			if _, err := regexp.Compile(key); err != nil {
+				return nil, fmt.Errorf("invalid redaction key pattern %q: %w", key, err)
+			}
+
+			alternatives[i] = "(?:" + key + ")"
+		}
+
+		r.keys = regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+	}
+
+	return r, nil
+}
+
+// DefaultRedactor redacts DefaultKeys with all Detectors.
+func DefaultRedactor() *Redactor {
+	r, err := NewRedactor(DefaultKeys, Detectors)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// SensitiveKey reports whether the values of key are redacted.
+func (r *Redactor) SensitiveKey(key string) bool {
+	return r.keys != nil && r.keys.MatchString(key)
+}
+
+// String redacts sensitive query parameters and detected values in s.
+func (r *Redactor) String(s string) string {
+	if r.keys != nil && strings.Contains(s, "=") {
+		s = queryParam.ReplaceAllStringFunc(s, func(pair string) string {
+			m := queryParam.FindStringSubmatch(pair)
+			if m[3] == "" || !r.SensitiveKey(m[2]) {
+				return pair
+			}
+			return m[1] + m[2] + "=" + Redacted
+		})
+	}
+
+	for _, d := range r.detectors {
+		s = d.redact(s)
+	}
+
+	return s
+}
+
+func (d Detector) redact(s string) string {
+	matches := d.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	replacement := d.Replacement
+	if replacement == "" {
+		replacement = Redacted
+	}
+
+	var b []byte
+	last := 0
+
+	for _, m := range matches {
+		if d.Find != nil {
+			for _, part := range d.Find(s[m[0]:m[1]]) {
+				b = append(b, s[last:m[0]+part[0]]...)
+				b = append(b, Redacted...)
+				last = m[0] + part[1]
+			}
+			continue
+		}
+
+		b = append(b, s[last:m[0]]...)
+		b = d.Pattern.ExpandString(b, replacement, s, m)
+		last = m[1]
+	}
+
+	if last == 0 {
+		return s
+	}
+
+	return string(append(b, s[last:]...))
+}
+
+// properties returns a redacted copy of props. Fields given to With share
+// their nested maps between entries, so nothing is redacted in place.
+func (r *Redactor) properties(props map[string]any) map[string]any {
+	if props == nil {
+		return nil
+	}
+
+	out := make(map[string]any, len(props))
+	for key, value := range props {
+		out[key] = r.value(key, value)
+	}
+
+	return out
+}
+
+func (r *Redactor) value(key string, v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+
+	if key != "" && r.SensitiveKey(key) {
+		return Redacted
+	}
+
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		return r.properties(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = r.value("", item)
+		}
+		return out
+	case json.RawMessage:
+		// Values logged with Any are already marshalled, so they are
+		// decoded again to look inside them.
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+
+		var decoded any
+		if err := dec.Decode(&decoded); err != nil {
+			return Redacted
+		}
+
+		return r.value("", decoded)
+	default:
+		return v
+	}
+}
+
+// cardNumbers finds the card numbers among the space-separated words of
+// run. A card may span several words, as when printed in groups, but
+// always whole ones, so the digits of a UUID or of "4111-1111-1111-1111-x"
+// are never taken for one. The longest card starting at a word wins.
+func cardNumbers(run string) [][2]int {
+	var words [][2]int
+	start := 0
+	for i := 0; i <= len(run); i++ {
+		if i == len(run) || run[i] == ' ' {
+			words = append(words, [2]int{start, i})
+			start = i + 1
+		}
+	}
+
+	var cards [][2]int
+
+	for i := 0; i < len(words); i++ {
+		end, digits := -1, 0
+
+		for j := i; j < len(words) && digits <= 19; j++ {
+			for _, c := range run[words[j][0]:words[j][1]] {
+				if c >= '0' && c <= '9' {
+					digits++
+				}
+			}
+
+			if cardNumber(run[words[i][0]:words[j][1]]) {
+				end = j
+			}
+		}
+
+		if end >= 0 {
+			cards = append(cards, [2]int{words[i][0], words[end][1]})
+			i = end
+		}
+	}
+
+	return cards
+}
+
+// cardNumber reports whether s is 13 to 19 digits with only single spaces or
+// dashes between them, starts like a payment card (2 to 6) and passes the
+// Luhn check. UUIDs fail on their letters or length, snowflake IDs on their
+// leading 1.
+func cardNumber(s string) bool {
+	if !cardGroups.MatchString(s) || s[0] < '2' || s[0] > '6' {
+		return false
+	}
+
+	return luhn(s)
+}
+
+// luhn reports whether the digits in s, ignoring separators, form a
+// plausible card number.
+func luhn(s string) bool {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
@@ -0,0 +1,174 @@
+package jsonlog
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// Handler is a slog.Handler writing through a Logger, in the same line
+// format and subject to the same level. Groups become nested objects in
+// the properties.
+type Handler struct {
+	logger *Logger
+	groups []string
+	attrs  []groupedAttrs
+}
+
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// Handler returns a slog.Handler for l. Install it as the process default
+// with slog.SetDefault(slog.New(l.Handler())), which also routes the log
+// package's default logger through it at INFO.
+func (l *Logger) Handler() *Handler {
+	return &Handler{logger: l}
+}
+
+// LogLogger returns a *log.Logger whose every line is logged at level, for
+// libraries such as net/http that take one.
+func (l *Logger) LogLogger(level Level) *log.Logger {
+	return slog.NewLogLogger(l.Handler(), slogLevel(level))
+}
+
+// fromSlog maps slog levels onto the nearest level at or below them, so
+// that e.g. slog.LevelWarn+2 is logged as WARN.
+func fromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(fromSlog(level))
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	props := make(map[string]any)
+
+	for _, ga := range h.attrs {
+		addAttrs(props, ga.groups, ga.attrs)
+	}
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	addAttrs(props, h.groups, attrs)
+
+	fields := make([]Field, 0, len(props))
+	for key, value := range props {
+		fields = append(fields, Field{key, value})
+	}
+
+	_, err := h.logger.logAt(r.Time, fromSlog(r.Level), r.Message, fields)
+
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], groupedAttrs{h.groups, attrs})
+
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+
+	return &h2
+}
+
+// addAttrs adds attrs to props under the nested groups. Groups are only
+// created once they hold an attribute, as slog handlers must leave empty
+// groups out.
+func addAttrs(props map[string]any, groups []string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+
+		if a.Value.Kind() == slog.KindGroup {
+			members := a.Value.Group()
+			if len(members) == 0 {
+				continue
+			}
+
+			inner := groups
+			if a.Key != "" {
+				inner = append(groups[:len(groups):len(groups)], a.Key)
+			}
+
+			addAttrs(props, inner, members)
+			continue
+		}
+
+		target := props
+		for _, g := range groups {
+			next, ok := target[g].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				target[g] = next
+			}
+			target = next
+		}
+
+		target[a.Key] = attrValue(a.Key, a.Value)
+	}
+}
+
+func attrValue(key string, v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	default:
+		return Any(key, v.Any()).Value
+	}
+}
@@ -0,0 +1,160 @@
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerConformance(t *testing.T) {
+	var buf bytes.Buffer
+
+	newHandler := func(t *testing.T) slog.Handler {
+		buf.Reset()
+
+		logger := New(&buf, LevelDebug)
+		logger.SetTraceLevel(LevelOff)
+
+		return logger.Handler()
+	}
+
+	// slogtest expects the built-in keys of slog's own handlers alongside
+	// the attributes, which jsonlog keeps under properties.
+	result := func(t *testing.T) map[string]any {
+		var line struct {
+			Level      string         `json:"level"`
+			Time       string         `json:"time"`
+			Message    string         `json:"message"`
+			Properties map[string]any `json:"properties"`
+		}
+
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+
+		m := line.Properties
+		if m == nil {
+			m = make(map[string]any)
+		}
+
+		m[slog.LevelKey] = line.Level
+		m[slog.MessageKey] = line.Message
+
+		if line.Time != "" {
+			m[slog.TimeKey] = line.Time
+		}
+
+		return m
+	}
+
+	slogtest.Run(t, newHandler, result)
+}
+
+func TestHandlerLevels(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected string
+	}{
+		{slog.LevelDebug - 4, "DEBUG"},
+		{slog.LevelDebug, "DEBUG"},
+		{slog.LevelInfo, "INFO"},
+		{slog.LevelInfo + 2, "INFO"},
+		{slog.LevelWarn, "WARN"},
+		{slog.LevelError, "ERROR"},
+		{slog.LevelError + 4, "ERROR"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			logger := slog.New(New(&buf, LevelDebug).Handler())
+			logger.Log(context.Background(), tc.level, "message")
+
+			lines := decodeLines(t, &buf)
+			assert.Equal(t, tc.expected, lines[0].Level)
+		})
+	}
+}
+
+func TestHandlerRespectsMinLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	jl := New(&buf, LevelWarn)
+	logger := slog.New(jl.Handler())
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelInfo))
+
+	// The handler follows level changes made on the logger.
+	jl.SetLevel(LevelDebug)
+	logger.Debug("kept too")
+
+	var messages []string
+	for _, line := range decodeLines(t, &buf) {
+		messages = append(messages, line.Message)
+	}
+
+	assert.Equal(t, []string{"kept", "kept too"}, messages)
+}
+
+func TestHandlerLineFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	jl := New(&buf, LevelInfo).With(String("service", "auth"))
+	logger := slog.New(jl.Handler()).With("component", "kafka").WithGroup("broker")
+
+	logger.Error("connection lost",
+		"address", "kafka:9092",
+		"attempt", 3,
+		"backoff", 250*time.Millisecond,
+		"err", errors.New("EOF"),
+		slog.Group("partition", "topic", "orders", "id", 2),
+	)
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "connection lost", line["message"])
+	assert.NotEmpty(t, line["time"])
+	assert.NotEmpty(t, line["trace"])
+
+	assert.Equal(t, map[string]any{
+		"service":   "auth",
+		"component": "kafka",
+		"broker": map[string]any{
+			"address": "kafka:9092",
+			"attempt": float64(3),
+			"backoff": "250ms",
+			"err":     "EOF",
+			"partition": map[string]any{
+				"topic": "orders",
+				"id":    float64(2),
+			},
+		},
+	}, line["properties"])
+
+	_, err := time.Parse(time.RFC3339, line["time"].(string))
+	assert.NoError(t, err)
+}
+
+func TestLogLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(&buf, LevelInfo).LogLogger(LevelWarn)
+	logger.Printf("http: TLS handshake error from %s: EOF", "10.0.0.1:5000")
+
+	lines := decodeLines(t, &buf)
+	assert.Equal(t, "WARN", lines[0].Level)
+	assert.Equal(t, "http: TLS handshake error from 10.0.0.1:5000: EOF", lines[0].Message)
+}
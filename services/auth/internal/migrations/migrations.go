@@ -0,0 +1,282 @@
+package migrations
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Width is the number of digits new migration versions are padded to.
+const Width = 6
+
+var (
+	fileRX = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nameRX = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// Set is the sorted list of migrations found in a directory, together with
+// any problems spotted while reading it.
+type Set struct {
+	Migrations []Migration
+	Issues     []string
+}
+
+// Load reads every *.sql file at the root of fsys. Files that cannot be
+// parsed are reported as issues rather than errors, so that status can still
+// show them.
+func Load(fsys fs.FS) (*Set, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{}
+	byVersion := make(map[uint]*Migration)
+	widths := make(map[int][]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		name := entry.Name()
+
+		match := fileRX.FindStringSubmatch(name)
+		if match == nil {
+			set.issuef("%s: file name does not match VERSION_name.(up|down).sql", name)
+			continue
+		}
+
+		v, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || v == 0 {
+			set.issuef("%s: invalid version %q", name, match[1])
+			continue
+		}
+
+		version := uint(v)
+		widths[len(match[1])] = append(widths[len(match[1])], name)
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			set.issuef("%s: version %d is also used by %q", name, version, m.Name)
+			continue
+		}
+
+		target := &m.Up
+		if match[3] == "down" {
+			target = &m.Down
+		}
+
+		if *target != "" {
+			set.issuef("%s: duplicate %s migration for version %d", name, match[3], version)
+			continue
+		}
+
+		*target = name
+
+		empty, err := isEmpty(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if empty {
+			set.issuef("%s: file contains no SQL statements", name)
+		}
+	}
+
+	for _, m := range byVersion {
+		set.Migrations = append(set.Migrations, *m)
+	}
+
+	sort.Slice(set.Migrations, func(i, j int) bool {
+		return set.Migrations[i].Version < set.Migrations[j].Version
+	})
+
+	for i, m := range set.Migrations {
+		if m.Up == "" {
+			set.issuef("%s: missing up migration", m)
+		}
+
+		if m.Down == "" {
+			set.issuef("%s: missing down migration", m)
+		}
+
+		expected := uint(1)
+		if i > 0 {
+			expected = set.Migrations[i-1].Version + 1
+		}
+
+		if m.Version != expected {
+			set.issuef("%s: numbering gap, expected version %d", m, expected)
+		}
+	}
+
+	if len(widths) > 1 {
+		counts := make([]string, 0, len(widths))
+		for width, names := range widths {
+			counts = append(counts, fmt.Sprintf("%d digits: %s", width, strings.Join(names, ", ")))
+		}
+
+		sort.Strings(counts)
+		set.issuef("inconsistent version widths (%s)", strings.Join(counts, "; "))
+	}
+
+	return set, nil
+}
+
+func (s *Set) issuef(format string, args ...any) {
+	s.Issues = append(s.Issues, fmt.Sprintf(format, args...))
+}
+
+// Latest returns the highest known version, or 0 if there are none.
+func (s *Set) Latest() uint {
+	if len(s.Migrations) == 0 {
+		return 0
+	}
+
+	return s.Migrations[len(s.Migrations)-1].Version
+}
+
+// PlanUp returns the migrations that still have to be applied on top of the
+// current version, in order.
+func (s *Set) PlanUp(current uint) []Migration {
+	var plan []Migration
+
+	for _, m := range s.Migrations {
+		if m.Version > current {
+			plan = append(plan, m)
+		}
+	}
+
+	return plan
+}
+
+// PlanDown returns the n migrations that would be reverted from the current
+// version, newest first.
+func (s *Set) PlanDown(current uint, n int) ([]Migration, error) {
+	var applied []Migration
+
+	for i := len(s.Migrations) - 1; i >= 0; i-- {
+		if s.Migrations[i].Version <= current {
+			applied = append(applied, s.Migrations[i])
+		}
+	}
+
+	if n > len(applied) {
+		return nil, fmt.Errorf("cannot revert %d migrations, only %d applied", n, len(applied))
+	}
+
+	return applied[:n], nil
+}
+
+// PlanGoto returns the migrations that would run to move from current to
+// target and whether they run up or down.
+func (s *Set) PlanGoto(current, target uint) (plan []Migration, up bool, err error) {
+	if target != 0 && !s.Has(target) {
+		return nil, false, fmt.Errorf("unknown version %d", target)
+	}
+
+	if target >= current {
+		for _, m := range s.Migrations {
+			if m.Version > current && m.Version <= target {
+				plan = append(plan, m)
+			}
+		}
+
+		return plan, true, nil
+	}
+
+	for i := len(s.Migrations) - 1; i >= 0; i-- {
+		m := s.Migrations[i]
+		if m.Version <= current && m.Version > target {
+			plan = append(plan, m)
+		}
+	}
+
+	return plan, false, nil
+}
+
+func (s *Set) Has(version uint) bool {
+	for _, m := range s.Migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create writes an empty up/down pair for the next version into dir and
+// returns the file names. The files are reported as empty until filled in.
+func Create(dir string, set *Set, name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !nameRX.MatchString(name) {
+		return nil, errors.New("migration name must only contain lowercase letters, digits and underscores")
+	}
+
+	version := set.Latest() + 1
+
+	var files []string
+
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%0*d_%s.%s.sql", Width, version, name, direction))
+
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return files, err
+		}
+
+		_, err = fmt.Fprintf(f, "-- %s (%s)\n", name, direction)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return files, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// isEmpty reports whether a file has nothing but whitespace and -- comments.
+func isEmpty(fsys fs.FS, name string) (bool, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false, nil
+		}
+	}
+
+	return true, scanner.Err()
+}
@@ -0,0 +1,161 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/betasve/go-commerce/services/auth/db"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func versions(plan []Migration) []uint {
+	var v []uint
+	for _, m := range plan {
+		v = append(v, m.Version)
+	}
+
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_create_users.up.sql":   file("CREATE TABLE users (id int);"),
+		"000001_create_users.down.sql": file("DROP TABLE users;"),
+		"000002_add_index.up.sql":      file("-- nothing yet\n\n"),
+		"000002_add_index.down.sql":    file("DROP INDEX users_idx;"),
+		"000004_add_roles.up.sql":      file("ALTER TABLE users ADD role text;"),
+		"0005_add_flags.up.sql":        file("ALTER TABLE users ADD flags int;"),
+		"0005_add_flags.down.sql":      file("ALTER TABLE users DROP flags;"),
+		"notes.sql":                    file("SELECT 1;"),
+		"README.md":                    file("ignored"),
+	}
+
+	set, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := versions(set.Migrations); len(got) != 4 || got[0] != 1 || got[3] != 5 {
+		t.Errorf("Expected versions [1 2 4 5], got %v", got)
+	}
+
+	expectedIssues := []string{
+		"notes.sql: file name does not match",
+		"000002_add_index.up.sql: file contains no SQL statements",
+		"4_add_roles: missing down migration",
+		"4_add_roles: numbering gap, expected version 3",
+		"inconsistent version widths",
+	}
+
+	for _, expected := range expectedIssues {
+		found := false
+		for _, issue := range set.Issues {
+			if strings.Contains(issue, expected) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected an issue containing %q, got %v", expected, set.Issues)
+		}
+	}
+
+	if len(set.Issues) != len(expectedIssues) {
+		t.Errorf("Expected %d issues, got %v", len(expectedIssues), set.Issues)
+	}
+}
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	set, err := Load(db.Migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, issue := range set.Issues {
+		if !strings.HasPrefix(issue, "inconsistent version widths") {
+			t.Errorf("Unexpected issue: %s", issue)
+		}
+	}
+}
+
+func TestPlans(t *testing.T) {
+	set := &Set{Migrations: []Migration{{Version: 1}, {Version: 2}, {Version: 3}}}
+
+	tests := []struct {
+		name       string
+		plan       func() ([]Migration, error)
+		expected   []uint
+		expectsErr bool
+	}{
+		{"Up from scratch", func() ([]Migration, error) { return set.PlanUp(0), nil }, []uint{1, 2, 3}, false},
+		{"Up from the middle", func() ([]Migration, error) { return set.PlanUp(2), nil }, []uint{3}, false},
+		{"Down two", func() ([]Migration, error) { return set.PlanDown(3, 2) }, []uint{3, 2}, false},
+		{"Down too far", func() ([]Migration, error) { return set.PlanDown(1, 2) }, nil, true},
+		{"Goto up", func() ([]Migration, error) { p, _, err := set.PlanGoto(1, 3); return p, err }, []uint{2, 3}, false},
+		{"Goto down", func() ([]Migration, error) { p, _, err := set.PlanGoto(3, 1); return p, err }, []uint{3, 2}, false},
+		{"Goto zero", func() ([]Migration, error) { p, _, err := set.PlanGoto(2, 0); return p, err }, []uint{2, 1}, false},
+		{"Goto unknown", func() ([]Migration, error) { p, _, err := set.PlanGoto(1, 7); return p, err }, nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := tc.plan()
+			if (err != nil) != tc.expectsErr {
+				t.Fatalf("Expected error %v, got %v", tc.expectsErr, err)
+			}
+
+			got := versions(plan)
+			if len(got) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, got)
+			}
+
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Fatalf("Expected %v, got %v", tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	set := &Set{Migrations: []Migration{{Version: 1}, {Version: 2}}}
+
+	files, err := Create(dir, set, "Add_Roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "000003_add_roles.up.sql"),
+		filepath.Join(dir, "000003_add_roles.down.sql"),
+	}
+
+	if len(files) != 2 || files[0] != expected[0] || files[1] != expected[1] {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	loaded, err := Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Issues) != 3 {
+		t.Errorf("Expected the new files to be reported as empty and as a gap, got %v", loaded.Issues)
+	}
+
+	if _, err := Create(dir, set, "add_roles"); err == nil {
+		t.Error("Expected an error when the files already exist")
+	}
+
+	if _, err := Create(dir, set, "add roles!"); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+}
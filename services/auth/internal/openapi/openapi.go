@@ -0,0 +1,237 @@
+// Package openapi models the parts of an OpenAPI 3.1 document the services
+// describe themselves with. Schemas follow JSON Schema 2020-12, as 3.1 does.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	Responses       map[string]*Response      `json:"responses,omitempty"`
+	Parameters      map[string]*Parameter     `json:"parameters,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Schema is the subset of JSON Schema the services use.
+type Schema struct {
+	Ref         string    `json:"$ref,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Format      string    `json:"format,omitempty"`
+	Enum        []any     `json:"enum,omitempty"`
+	Const       any       `json:"const,omitempty"`
+	OneOf       []*Schema `json:"oneOf,omitempty"`
+	Not         *Schema   `json:"not,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+}
+
+// Ref returns a schema pointing at a component schema.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Nothing returns a schema no value matches. As additionalProperties it
+// closes an object to the listed properties.
+func Nothing() *Schema {
+	return &Schema{Not: &Schema{}}
+}
+
+// Ptr is a helper for the optional numeric keywords.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Operation returns the operation for method on path, or nil.
+func (d *Document) Operation(method, path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+
+	return *item.slot(method)
+}
+
+// AddOperation registers op for method on path.
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	if d.Paths == nil {
+		d.Paths = make(map[string]*PathItem)
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+
+	*item.slot(method) = op
+}
+
+// Operations lists every "METHOD /path" in the document, sorted.
+func (d *Document) Operations() []string {
+	var ops []string
+
+	for path, item := range d.Paths {
+		for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+			if *item.slot(method) != nil {
+				ops = append(ops, method+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(ops)
+
+	return ops
+}
+
+func (p *PathItem) slot(method string) **Operation {
+	switch strings.ToUpper(method) {
+	case "GET":
+		return &p.Get
+	case "POST":
+		return &p.Post
+	case "PUT":
+		return &p.Put
+	case "PATCH":
+		return &p.Patch
+	case "DELETE":
+		return &p.Delete
+	default:
+		var none *Operation
+		return &none
+	}
+}
+
+// ResolveSchema follows s's $ref, if any, into the document's components.
+func (d *Document) ResolveSchema(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	return s
+}
+
+// ResolveParameter follows p's $ref, if any, into the document's components.
+func (d *Document) ResolveParameter(p Parameter) Parameter {
+	if p.Ref == "" {
+		return p
+	}
+
+	if resolved, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]; ok {
+		return *resolved
+	}
+
+	return p
+}
+
+// ResolveResponse follows r's $ref, if any, into the document's components.
+func (d *Document) ResolveResponse(r *Response) *Response {
+	if r == nil || r.Ref == "" {
+		return r
+	}
+
+	return d.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+}
+
+// PathTemplate converts an httprouter pattern to an OpenAPI path template,
+// e.g. /v1/users/:id to /v1/users/{id}.
+func PathTemplate(pattern string) string {
+	segments := strings.Split(pattern, "/")
+
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
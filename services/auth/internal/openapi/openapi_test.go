@@ -0,0 +1,51 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathTemplate(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"/v1/users", "/v1/users"},
+		{"/v1/users/:id", "/v1/users/{id}"},
+		{"/v1/users/:id/roles/:role", "/v1/users/{id}/roles/{role}"},
+		{"/static/*filepath", "/static/{filepath}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			assert.Equal(t, tc.expected, PathTemplate(tc.pattern))
+		})
+	}
+}
+
+func TestDocumentOperations(t *testing.T) {
+	doc := &Document{
+		Components: Components{
+			Schemas:    map[string]*Schema{"User": {Type: "object"}},
+			Parameters: map[string]*Parameter{"ID": {Name: "id", In: "path"}},
+			Responses:  map[string]*Response{"NotFound": {Description: "missing"}},
+		},
+	}
+
+	show := &Operation{OperationID: "show"}
+	doc.AddOperation("GET", "/users/{id}", show)
+	doc.AddOperation("DELETE", "/users/{id}", &Operation{OperationID: "delete"})
+	doc.AddOperation("POST", "/users", &Operation{OperationID: "create"})
+
+	assert.Same(t, show, doc.Operation("get", "/users/{id}"))
+	assert.Nil(t, doc.Operation("PATCH", "/users/{id}"))
+	assert.Nil(t, doc.Operation("GET", "/roles"))
+	assert.Nil(t, doc.Operation("OPTIONS", "/users"))
+	assert.Equal(t, []string{"DELETE /users/{id}", "GET /users/{id}", "POST /users"}, doc.Operations())
+
+	assert.Equal(t, "object", doc.ResolveSchema(Ref("User")).Type)
+	assert.Nil(t, doc.ResolveSchema(Ref("Role")))
+	assert.Equal(t, "id", doc.ResolveParameter(Parameter{Ref: "#/components/parameters/ID"}).Name)
+	assert.Equal(t, "missing", doc.ResolveResponse(&Response{Ref: "#/components/responses/NotFound"}).Description)
+}
@@ -0,0 +1,266 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// patterns caches compiled Schema.Pattern values. Go's regexp is RE2, which
+// covers the patterns the services use.
+var patterns sync.Map
+
+// Validate checks v, as decoded by encoding/json with UseNumber, against s.
+// Each invalid value is recorded in errs under its location, e.g. "name" or
+// "users[0].email", keeping the first message per location. field is the
+// location of v itself and may be empty for the root.
+func (d *Document) Validate(s *Schema, v any, field string, errs map[string]string) {
+	s = d.ResolveSchema(s)
+	if s == nil {
+		return
+	}
+
+	add := func(message string) {
+		key := field
+		if key == "" {
+			key = "body"
+		}
+
+		if _, exists := errs[key]; !exists {
+			errs[key] = message
+		}
+	}
+
+	if s.Not != nil && d.valid(s.Not, v) {
+		add("is not allowed")
+		return
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		add(typeMessage(s.Type))
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			if d.valid(option, v) {
+				matches++
+			}
+		}
+
+		if matches != 1 {
+			add("must match exactly one of the allowed shapes")
+			return
+		}
+	}
+
+	if s.Const != nil && !equal(v, s.Const) {
+		add(fmt.Sprintf("must be %v", s.Const))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if equal(v, allowed) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			values := make([]string, len(s.Enum))
+			for i, allowed := range s.Enum {
+				values[i] = fmt.Sprint(allowed)
+			}
+
+			add("must be one of " + strings.Join(values, ", "))
+			return
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+
+		switch {
+		case s.MinLength != nil && length < *s.MinLength && v == "":
+			add("can't be blank")
+		case s.MinLength != nil && length < *s.MinLength:
+			add(fmt.Sprintf("must be at least %d characters long", *s.MinLength))
+		case s.MaxLength != nil && length > *s.MaxLength:
+			add(fmt.Sprintf("must be at most %d characters long", *s.MaxLength))
+		case s.Pattern != "" && !pattern(s.Pattern).MatchString(v):
+			add("has an invalid format")
+		}
+
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			add(typeMessage("number"))
+			return
+		}
+
+		switch {
+		case s.Minimum != nil && n < *s.Minimum:
+			add("must be at least " + formatFloat(*s.Minimum))
+		case s.Maximum != nil && n > *s.Maximum:
+			add("must be at most " + formatFloat(*s.Maximum))
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				if _, exists := errs[join(field, name)]; !exists {
+					errs[join(field, name)] = "must be provided"
+				}
+			}
+		}
+
+		// Sorted so that the first message per location is deterministic.
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				d.Validate(property, v[name], join(field, name), errs)
+			} else if s.AdditionalProperties != nil {
+				d.Validate(s.AdditionalProperties, v[name], join(field, name), errs)
+			}
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				d.Validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+			}
+		}
+	}
+}
+
+// ValidateString checks a path, query or header value against s. The raw
+// string is converted to the schema's type first, so "abc" fails an integer
+// schema with a type error rather than matching as a string.
+func (d *Document) ValidateString(s *Schema, raw, field string, errs map[string]string) {
+	var v any = raw
+
+	if resolved := d.ResolveSchema(s); resolved != nil {
+		switch resolved.Type {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(raw, 64); err == nil {
+				v = json.Number(raw)
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(raw); err == nil {
+				v = b
+			}
+		}
+	}
+
+	d.Validate(s, v, field, errs)
+}
+
+func (d *Document) valid(s *Schema, v any) bool {
+	errs := make(map[string]string)
+	d.Validate(s, v, "", errs)
+
+	return len(errs) == 0
+}
+
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(string(n), 10, 64)
+		return err == nil
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "null":
+		return v == nil
+	default:
+		return true
+	}
+}
+
+func typeMessage(typ string) string {
+	switch typ {
+	case "integer", "object", "array":
+		return "must be an " + typ
+	case "null":
+		return "must be null"
+	default:
+		return "must be a " + typ
+	}
+}
+
+// equal compares a decoded value with an enum or const from the spec, which
+// may be any Go string or number.
+func equal(v, want any) bool {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return false
+		}
+
+		switch want := want.(type) {
+		case int:
+			return f == float64(want)
+		case int64:
+			return f == float64(want)
+		case float64:
+			return f == want
+		}
+
+		return false
+	}
+
+	return v == want
+}
+
+func pattern(p string) *regexp.Regexp {
+	if rx, ok := patterns.Load(p); ok {
+		return rx.(*regexp.Regexp)
+	}
+
+	rx := regexp.MustCompile(p)
+	patterns.Store(p, rx)
+
+	return rx
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+
+	return field + "." + name
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	doc := &Document{
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"Role": {Type: "string", Enum: []any{"admin", "customer"}},
+				"User": {
+					Type:                 "object",
+					Required:             []string{"name"},
+					AdditionalProperties: Nothing(),
+					Properties: map[string]*Schema{
+						"name":  {Type: "string", MinLength: Ptr(2), MaxLength: Ptr(5)},
+						"code":  {Type: "string", Pattern: `^[a-z]+$`},
+						"age":   {Type: "integer", Minimum: Ptr(0.0), Maximum: Ptr(150.0)},
+						"kind":  {Const: "user"},
+						"roles": {Type: "array", Items: Ref("Role")},
+						"tags":  {Type: "object", AdditionalProperties: &Schema{Type: "boolean"}},
+						"id":    {OneOf: []*Schema{{Type: "integer"}, {Type: "string", MinLength: Ptr(1)}}},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]string
+	}{
+		{"Valid", `{"name":"Ann","code":"abc","age":30,"kind":"user","roles":["admin"],"tags":{"x":true},"id":"a"}`, map[string]string{}},
+		{"Wrong root type", `[]`, map[string]string{"body": "must be an object"}},
+		{"Missing required", `{}`, map[string]string{"name": "must be provided"}},
+		{"Unknown property", `{"name":"Ann","admin":true}`, map[string]string{"admin": "is not allowed"}},
+		{"Blank string", `{"name":""}`, map[string]string{"name": "can't be blank"}},
+		{"Short string", `{"name":"A"}`, map[string]string{"name": "must be at least 2 characters long"}},
+		{"Long string", `{"name":"Annabel"}`, map[string]string{"name": "must be at most 5 characters long"}},
+		{"Pattern", `{"name":"Ann","code":"A1"}`, map[string]string{"code": "has an invalid format"}},
+		{"Not an integer", `{"name":"Ann","age":1.5}`, map[string]string{"age": "must be an integer"}},
+		{"Below minimum", `{"name":"Ann","age":-1}`, map[string]string{"age": "must be at least 0"}},
+		{"Above maximum", `{"name":"Ann","age":151}`, map[string]string{"age": "must be at most 150"}},
+		{"Const", `{"name":"Ann","kind":"admin"}`, map[string]string{"kind": "must be user"}},
+		{"Enum in array", `{"name":"Ann","roles":["admin","root"]}`, map[string]string{"roles[1]": "must be one of admin, customer"}},
+		{"Additional properties", `{"name":"Ann","tags":{"x":"yes"}}`, map[string]string{"tags.x": "must be a boolean"}},
+		{"OneOf", `{"name":"Ann","id":true}`, map[string]string{"id": "must match exactly one of the allowed shapes"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := make(map[string]string)
+			doc.Validate(Ref("User"), decode(t, tc.value), "", errs)
+
+			assert.Equal(t, tc.expected, errs)
+		})
+	}
+}
+
+func TestValidateString(t *testing.T) {
+	doc := &Document{}
+	id := &Schema{Type: "integer", Minimum: Ptr(1.0)}
+
+	tests := []struct {
+		name     string
+		schema   *Schema
+		raw      string
+		expected map[string]string
+	}{
+		{"Integer", id, "42", map[string]string{}},
+		{"Not an integer", id, "abc", map[string]string{"id": "must be an integer"}},
+		{"Fraction", id, "1.5", map[string]string{"id": "must be an integer"}},
+		{"Below minimum", id, "0", map[string]string{"id": "must be at least 1"}},
+		{"Boolean", &Schema{Type: "boolean"}, "true", map[string]string{}},
+		{"String", &Schema{Type: "string", Enum: []any{"a", "b"}}, "c", map[string]string{"id": "must be one of a, b"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := make(map[string]string)
+			doc.ValidateString(tc.schema, tc.raw, "id", errs)
+
+			assert.Equal(t, tc.expected, errs)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Memory drops keys that are back to a full
+// burst, and so no different from keys it has never seen.
+const sweepInterval = time.Minute
+
+// Memory keeps limits in the process. Each replica enforces them on its own,
+// so it is meant for development and single instance deployments.
+type Memory struct {
+	Now func() time.Time
+
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		Now:  time.Now,
+		tats: make(map[string]time.Time),
+	}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		for k, tat := range m.tats {
+			if !tat.After(now) {
+				delete(m.tats, k)
+			}
+		}
+
+		m.lastSweep = now
+	}
+
+	result, tat := gcra(now, m.tats[key], limit)
+	m.tats[key] = tat
+
+	return result, nil
+}
@@ -0,0 +1,115 @@
+// Package ratelimit implements the generic cell rate algorithm (GCRA) over
+// pluggable stores, so that limits can be shared by every replica of a
+// service.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Rate requests per second on average and up to Burst at once.
+// A zero Limit does not restrict anything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%g:%d", l.Rate, l.Burst)
+}
+
+// emission is the time one request "costs".
+func (l Limit) emission() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+// Result describes the state of a key after a call to Allow.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before the next request can succeed. It
+	// is zero when the request was allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the key is back to a full burst.
+	ResetAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key is within limit.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// gcra applies one request at now to a key whose theoretical arrival time is
+// tat, returning the result and the new tat to store when it was allowed.
+func gcra(now, tat time.Time, limit Limit) (Result, time.Time) {
+	emission := limit.emission()
+	tolerance := emission * time.Duration(limit.Burst)
+
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(emission)
+	diff := now.Sub(newTAT.Add(-tolerance))
+
+	if diff < 0 {
+		return Result{
+			Allowed:    false,
+			Limit:      limit.Burst,
+			RetryAfter: -diff,
+			ResetAfter: tat.Sub(now),
+		}, tat
+	}
+
+	return Result{
+		Allowed:    true,
+		Limit:      limit.Burst,
+		Remaining:  int(diff / emission),
+		ResetAfter: newTAT.Sub(now),
+	}, newTAT
+}
+
+// ParseRouteLimit parses "METHOD /pattern=RATE:BURST".
+func ParseRouteLimit(s string) (route string, limit Limit, err error) {
+	route, value, ok := strings.Cut(s, "=")
+	if !ok || len(strings.Fields(route)) != 2 {
+		return "", Limit{}, fmt.Errorf("rate limit %q: expected METHOD /pattern=RATE:BURST", s)
+	}
+
+	limit, err = ParseLimit(value)
+	if err != nil {
+		return "", Limit{}, fmt.Errorf("rate limit %q: %w", s, err)
+	}
+
+	return strings.Join(strings.Fields(route), " "), limit, nil
+}
+
+// ParseLimit parses "RATE:BURST", with RATE in requests per second.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q: expected RATE:BURST", s)
+	}
+
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 || math.IsInf(r, 0) || math.IsNaN(r) {
+		return Limit{}, fmt.Errorf("limit %q: invalid rate", s)
+	}
+
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid burst", s)
+	}
+
+	return Limit{Rate: r, Burst: b}, nil
+}
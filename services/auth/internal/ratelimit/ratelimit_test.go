@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2025, 3, 26, 15, 4, 5, 0, time.UTC)
+
+// testLimiterContract checks a Limiter whose clock is moved by advance.
+func testLimiterContract(t *testing.T, newLimiter func(t *testing.T) (Limiter, func(time.Duration))) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+
+	t.Run("Allows a burst then rejects", func(t *testing.T) {
+		l, _ := newLimiter(t)
+
+		for i := 2; i >= 0; i-- {
+			res, err := l.Allow(ctx, "ip:1", limit)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 3, res.Limit)
+			assert.Equal(t, i, res.Remaining)
+		}
+
+		res, err := l.Allow(ctx, "ip:1", limit)
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+		assert.Equal(t, time.Second, res.RetryAfter)
+		assert.Equal(t, 3*time.Second, res.ResetAfter)
+	})
+
+	t.Run("Refills at the rate", func(t *testing.T) {
+		l, advance := newLimiter(t)
+
+		for range 3 {
+			l.Allow(ctx, "ip:1", limit)
+		}
+
+		advance(time.Second)
+
+		res, err := l.Allow(ctx, "ip:1", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+
+		advance(10 * time.Second)
+
+		res, err = l.Allow(ctx, "ip:1", limit)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, res.Remaining)
+	})
+
+	t.Run("Keeps keys apart", func(t *testing.T) {
+		l, _ := newLimiter(t)
+
+		for range 3 {
+			l.Allow(ctx, "ip:1", limit)
+		}
+
+		res, err := l.Allow(ctx, "ip:2", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("Does not restrict a zero limit", func(t *testing.T) {
+		l, _ := newLimiter(t)
+
+		for range 10 {
+			res, err := l.Allow(ctx, "ip:1", Limit{})
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testLimiterContract(t, func(t *testing.T) (Limiter, func(time.Duration)) {
+		now := start
+
+		m := NewMemory()
+		m.Now = func() time.Time { return now }
+
+		return m, func(d time.Duration) { now = now.Add(d) }
+	})
+}
+
+func TestMemorySweep(t *testing.T) {
+	now := start
+
+	m := NewMemory()
+	m.Now = func() time.Time { return now }
+
+	m.Allow(context.Background(), "ip:1", Limit{Rate: 1, Burst: 3})
+	now = now.Add(2 * sweepInterval)
+	m.Allow(context.Background(), "ip:2", Limit{Rate: 1, Burst: 3})
+
+	assert.Len(t, m.tats, 1)
+}
+
+func TestRedis(t *testing.T) {
+	testLimiterContract(t, func(t *testing.T) (Limiter, func(time.Duration)) {
+		srv := miniredis.RunT(t)
+		now := start
+		srv.SetTime(now)
+
+		client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		return &Redis{Client: client, Prefix: "auth:"}, func(d time.Duration) {
+			now = now.Add(d)
+			srv.SetTime(now)
+			srv.FastForward(d)
+		}
+	})
+}
+
+func TestParseRouteLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		route      string
+		limit      Limit
+		expectsErr bool
+	}{
+		{"Limit", "POST /v1/users=0.1:3", "POST /v1/users", Limit{0.1, 3}, false},
+		{"Extra spaces", "GET  /v1/users=2:4", "GET /v1/users", Limit{2, 4}, false},
+		{"Exempt", "GET /v1/health/live=0:0", "GET /v1/health/live", Limit{}, false},
+		{"Missing method", "/v1/users=1:1", "", Limit{}, true},
+		{"Missing limit", "GET /v1/users", "", Limit{}, true},
+		{"Bad rate", "GET /v1/users=fast:1", "", Limit{}, true},
+		{"Negative burst", "GET /v1/users=1:-1", "", Limit{}, true},
+		{"Second limit", "GET /v1/users=1:1,2:2", "", Limit{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			route, limit, err := ParseRouteLimit(tc.input)
+			if tc.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.route, route)
+			assert.Equal(t, tc.limit, limit)
+		})
+	}
+}
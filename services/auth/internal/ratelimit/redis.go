@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript is gcra on the server, so that concurrent replicas cannot both
+// take the last request of a burst. The clock is Redis's for the same reason.
+// Times are in microseconds, which a Lua number holds exactly.
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local tolerance = emission * tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+local diff = now - (new_tat - tolerance)
+
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil((new_tat - now) / 1000))
+
+return {1, math.floor(diff / emission), 0, new_tat - now}
+`)
+
+// Redis keeps limits in Redis, or anything speaking its protocol and Lua
+// scripting, so that they hold across replicas.
+type Redis struct {
+	Client redis.Scripter
+	// Prefix namespaces the keys, e.g. per service.
+	Prefix string
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	emission := limit.emission().Microseconds()
+
+	values, err := gcraScript.Run(ctx, r.Client, []string{r.Prefix + key}, emission, limit.Burst).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
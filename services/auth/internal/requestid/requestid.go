@@ -0,0 +1,83 @@
+// Package requestid carries a request identifier through a context so that
+// log entries, error responses and outgoing calls for the same request can be
+// correlated across services.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Header is the HTTP header, and the message header name, the identifier is
+// read from and propagated in.
+const Header = "X-Request-ID"
+
+// validRX limits what is accepted from clients, so that an identifier can be
+// logged and echoed back without escaping.
+var validRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// New returns a random, UUIDv4 formatted identifier.
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Valid reports whether id is acceptable from an untrusted caller.
+func Valid(id string) bool {
+	return validRX.MatchString(id)
+}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request identifier, or "" when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Inject passes the identifier from ctx to set, if there is one. set is
+// usually the Set method of a header map, e.g. a Kafka message's headers.
+func Inject(ctx context.Context, set func(key, value string)) {
+	if id := FromContext(ctx); id != "" {
+		set(Header, id)
+	}
+}
+
+// Transport adds the request identifier of each outgoing request's context to
+// its headers. A nil Base means http.DefaultTransport.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	id := FromContext(r.Context())
+	if id == "" || r.Header.Get(Header) != "" {
+		return t.base().RoundTrip(r)
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	r = r.Clone(r.Context())
+	r.Header.Set(Header, id)
+
+	return t.base().RoundTrip(r)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
@@ -0,0 +1,99 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	id := New()
+
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	assert.True(t, Valid(id))
+	assert.NotEqual(t, id, New())
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{"UUID", "0b7e1c5e-3f5a-4d2b-9c1e-2a6f0d8b4c3a", true},
+		{"Dotted with colons", "checkout.svc:42_a", true},
+		{"Empty", "", false},
+		{"Spaces", "abc def", false},
+		{"Newline injection", "abc\n{\"level\":\"FATAL\"}", false},
+		{"Too long", string(make([]byte, 129)), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Valid(tc.id))
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, "", FromContext(context.Background()))
+	assert.Equal(t, "abc", FromContext(NewContext(context.Background(), "abc")))
+}
+
+func TestInject(t *testing.T) {
+	headers := map[string]string{}
+	set := func(key, value string) { headers[key] = value }
+
+	Inject(context.Background(), set)
+	assert.Empty(t, headers)
+
+	Inject(NewContext(context.Background(), "abc"), set)
+	assert.Equal(t, map[string]string{Header: "abc"}, headers)
+}
+
+func TestTransport(t *testing.T) {
+	var received string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(Header)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	tests := []struct {
+		name     string
+		ctxID    string
+		headerID string
+		expected string
+	}{
+		{"Propagates the context id", "abc", "", "abc"},
+		{"Keeps an explicit header", "abc", "def", "def"},
+		{"Adds nothing without an id", "", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(NewContext(context.Background(), tc.ctxID), http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.headerID != "" {
+				req.Header.Set(Header, tc.headerID)
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			assert.Equal(t, tc.expected, received)
+			assert.Equal(t, tc.headerID, req.Header.Get(Header))
+		})
+	}
+}
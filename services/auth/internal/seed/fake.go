@@ -0,0 +1,55 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+)
+
+var (
+	firstNames = []string{
+		"Olivia", "Liam", "Emma", "Noah", "Amelia", "Oliver", "Sophia", "Elijah",
+		"Charlotte", "James", "Isabella", "William", "Mia", "Benjamin", "Evelyn",
+		"Lucas", "Harper", "Henry", "Camila", "Theodore", "Gianna", "Jack",
+		"Abigail", "Levi", "Luna", "Alexander", "Ella", "Jackson", "Elizabeth",
+		"Mateo", "Sofia", "Daniel", "Emily", "Michael", "Avery", "Mason",
+		"Maria", "Sebastian", "Elena", "Ethan", "Ivan", "Georgi", "Yana",
+	}
+
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller",
+		"Davis", "Rodriguez", "Martinez", "Hernandez", "Lopez", "Gonzalez",
+		"Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+		"Lee", "Perez", "Thompson", "White", "Harris", "Sanchez", "Clark",
+		"Ramirez", "Lewis", "Robinson", "Walker", "Young", "Allen", "King",
+		"Wright", "Scott", "Torres", "Nguyen", "Hill", "Flores", "Petrov",
+		"Ivanova", "Dimitrov", "O'Brien", "Smith-Jones",
+	}
+)
+
+// FakeUsers generates n customers with realistic names. The same seed always
+// yields the same users, so reseeding with it is idempotent.
+func FakeUsers(n int, seed uint64, password string) []User {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	users := make([]User, 0, n)
+
+	for i := 1; i <= n; i++ {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+
+		users = append(users, User{
+			Name:     first + " " + last,
+			Email:    fmt.Sprintf("%s.%s.%d@example.com", emailPart(first), emailPart(last), i),
+			Password: password,
+			Roles:    []string{data.RoleCustomer},
+		})
+	}
+
+	return users
+}
+
+func emailPart(name string) string {
+	return strings.ToLower(strings.NewReplacer("'", "", " ", "").Replace(name))
+}
@@ -0,0 +1,213 @@
+// Package seed loads declarative fixtures into the auth service's models.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+	"github.com/betasve/go-commerce/services/auth/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures is the document format of a fixtures file. JSON is valid YAML, so
+// both are read by the same decoder.
+type Fixtures struct {
+	Users []User `yaml:"users" json:"users"`
+}
+
+type User struct {
+	Name     string   `yaml:"name" json:"name"`
+	Email    string   `yaml:"email" json:"email"`
+	Password string   `yaml:"password" json:"password"`
+	Roles    []string `yaml:"roles" json:"roles"`
+}
+
+// Result counts what happened to each fixture.
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged", r.Created, r.Updated, r.Unchanged)
+}
+
+// LoadFile reads a YAML or JSON fixtures file. Unknown keys are rejected so
+// that typos, or sections meant for another service, do not pass silently.
+func LoadFile(path string) (*Fixtures, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var f Fixtures
+
+	err = dec.Decode(&f)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// Validate checks every fixture with the same rules the API applies.
+func (f *Fixtures) Validate() error {
+	var errs []error
+	emails := make([]string, 0, len(f.Users))
+
+	for i, u := range f.Users {
+		v := validator.New()
+
+		data.ValidateUser(v, data.NewUser(u.Name, u.Email, u.Password))
+		data.ValidateRoles(v, u.Roles)
+
+		for key, message := range v.Errors {
+			errs = append(errs, fmt.Errorf("users[%d] (%s): %s %s", i, u.Email, key, message))
+		}
+
+		emails = append(emails, u.Email)
+	}
+
+	if !validator.Unique(emails) {
+		errs = append(errs, errors.New("users: emails must be unique"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Seeder applies fixtures idempotently: users are matched by email and only
+// written when they differ from the fixture.
+type Seeder struct {
+	Models  data.Models
+	Workers int
+}
+
+func (s Seeder) Apply(ctx context.Context, f *Fixtures) (Result, error) {
+	err := f.Validate()
+	if err != nil {
+		return Result{}, err
+	}
+
+	var (
+		mu     sync.Mutex
+		result Result
+		errs   []error
+		wg     sync.WaitGroup
+		jobs   = make(chan User)
+	)
+
+	// Password hashing dominates, so fixtures are applied in parallel.
+	for i := 0; i < max(s.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for u := range jobs {
+				outcome, err := s.applyUser(ctx, u)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, fmt.Errorf("%s: %w", u.Email, err))
+				case outcome == created:
+					result.Created++
+				case outcome == updated:
+					result.Updated++
+				default:
+					result.Unchanged++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, u := range f.Users {
+		jobs <- u
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return result, errors.Join(errs...)
+}
+
+type outcome int
+
+const (
+	unchanged outcome = iota
+	created
+	updated
+)
+
+func (s Seeder) applyUser(ctx context.Context, f User) (outcome, error) {
+	user, err := s.Models.Users.GetByEmail(ctx, f.Email)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		user = data.NewUser(f.Name, f.Email, f.Password)
+
+		err = s.Models.Users.Insert(ctx, user)
+		if err != nil {
+			return unchanged, err
+		}
+
+		return created, s.Models.Roles.SetForUser(ctx, user.ID, f.Roles)
+	case err != nil:
+		return unchanged, err
+	}
+
+	result := unchanged
+
+	matches, err := user.Password.Matches(f.Password)
+	if err != nil {
+		return unchanged, err
+	}
+
+	if user.Name != f.Name || !matches {
+		user.Name = f.Name
+
+		if !matches {
+			err = user.Password.Set(f.Password)
+			if err != nil {
+				return unchanged, err
+			}
+		}
+
+		err = s.Models.Users.Update(ctx, user)
+		if err != nil {
+			return unchanged, err
+		}
+
+		result = updated
+	}
+
+	roles, err := s.Models.Roles.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return result, err
+	}
+
+	wanted := slices.Clone(f.Roles)
+	slices.Sort(wanted)
+
+	if !slices.Equal(roles, wanted) {
+		err = s.Models.Roles.SetForUser(ctx, user.ID, f.Roles)
+		if err != nil {
+			return result, err
+		}
+
+		result = updated
+	}
+
+	return result, nil
+}
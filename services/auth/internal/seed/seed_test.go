@@ -0,0 +1,141 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/betasve/go-commerce/services/auth/internal/data"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		expectedErr string
+		expectedLen int
+	}{
+		{"YAML fixtures", "users.yaml", "users:\n  - name: Jane Doe\n    email: jane@example.com\n    password: Password123\n    roles: [admin]\n", "", 1},
+		{"JSON fixtures", "users.json", `{"users":[{"name":"Jane Doe","email":"jane@example.com","password":"Password123"}]}`, "", 1},
+		{"Empty file", "empty.yaml", "", "", 0},
+		{"Unknown section", "products.yaml", "products:\n  - sku: ABC\n", "field products not found", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := LoadFile(path)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tc.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(f.Users) != tc.expectedLen {
+				t.Errorf("Expected %d users, got %d", tc.expectedLen, len(f.Users))
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := &Fixtures{Users: []User{
+		{Name: "Jane Doe", Email: "jane@example.com", Password: "Password123", Roles: []string{"wizard"}},
+		{Name: "Jane Doe", Email: "jane@example.com", Password: "short"},
+	}}
+
+	err := f.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+
+	for _, expected := range []string{"roles contains an unknown role", "password too short", "emails must be unique"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestSeederApplyIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	seeder := Seeder{Models: models, Workers: 2}
+
+	f := &Fixtures{Users: []User{
+		{Name: "Admin User", Email: "admin@example.com", Password: "Password123", Roles: []string{data.RoleAdmin}},
+		{Name: "Customer User", Email: "customer@example.com", Password: "Password123", Roles: []string{data.RoleCustomer}},
+	}}
+
+	result, err := seeder.Apply(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (Result{Created: 2}) {
+		t.Errorf("Expected 2 created, got %v", result)
+	}
+
+	result, err = seeder.Apply(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (Result{Unchanged: 2}) {
+		t.Errorf("Expected 2 unchanged, got %v", result)
+	}
+
+	f.Users[1].Name = "Customer Renamed"
+	f.Users[1].Roles = []string{data.RoleCustomer, data.RoleAdmin}
+
+	result, err = seeder.Apply(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (Result{Updated: 1, Unchanged: 1}) {
+		t.Errorf("Expected 1 updated and 1 unchanged, got %v", result)
+	}
+
+	user, err := models.Users.GetByEmail(ctx, "customer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, _ := models.Roles.GetAllForUser(ctx, user.ID)
+	if user.Name != "Customer Renamed" || len(roles) != 2 {
+		t.Errorf("Expected the renamed user with two roles, got %q with %v", user.Name, roles)
+	}
+}
+
+func TestFakeUsers(t *testing.T) {
+	first := FakeUsers(50, 7, "Password123")
+	second := FakeUsers(50, 7, "Password123")
+
+	if len(first) != 50 {
+		t.Fatalf("Expected 50 users, got %d", len(first))
+	}
+
+	for i := range first {
+		if first[i].Email != second[i].Email {
+			t.Fatalf("Expected the same seed to give the same users, got %q and %q", first[i].Email, second[i].Email)
+		}
+	}
+
+	err := (&Fixtures{Users: first}).Validate()
+	if err != nil {
+		t.Errorf("Expected fake users to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+// Package tlsconfig builds server TLS configurations from certificate files
+// and swaps in new ones when the files change, so that certificates can be
+// rotated without a restart. Connections already established keep the
+// certificate they were opened with.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Client authentication modes. Optional verifies a certificate when the
+// client sends one; require rejects clients without a valid one.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// ClientAuthModes and MinVersions list the values Config accepts.
+var (
+	ClientAuthModes = []string{ClientAuthNone, ClientAuthOptional, ClientAuthRequire}
+	MinVersions     = []string{"1.2", "1.3"}
+)
+
+// cipherSuites are the TLS 1.2 suites offered: forward secret AEAD ciphers
+// only. TLS 1.3 suites are not configurable and are all sound.
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile holds the PEM certificates client certificates must
+	// chain to. It is required unless ClientAuth is none.
+	ClientCAFile string
+	ClientAuth   string
+
+	// MinVersion is 1.2 or 1.3, defaulting to 1.2.
+	MinVersion string
+}
+
+// Reloader holds the current TLS configuration built from the files in
+// Config.
+type Reloader struct {
+	cfg Config
+
+	current atomic.Pointer[tls.Config]
+
+	mu    sync.Mutex
+	stamp []fileStamp
+}
+
+// New loads the files in cfg, failing if they are unusable.
+func New(cfg Config) (*Reloader, error) {
+	if cfg.ClientAuth == "" {
+		cfg.ClientAuth = ClientAuthNone
+	}
+	if cfg.MinVersion == "" {
+		cfg.MinVersion = "1.2"
+	}
+
+	r := &Reloader{cfg: cfg}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the configuration for an http.Server. Each handshake
+// uses whatever configuration is current at the time.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.current.Load().MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.current.Load().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload reads the files again. On failure the current configuration is
+// kept, so a half-written rotation does not take the server down.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	config, err := build(r.cfg)
+	if err != nil {
+		return err
+	}
+
+	r.current.Store(config)
+	r.stamp = stamp
+
+	return nil
+}
+
+// Changed reports whether any of the files was modified, replaced or
+// resized since the last successful load.
+func (r *Reloader) Changed() (bool, error) {
+	stamp, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range stamp {
+		if stamp[i] != r.stamp[i] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// NotAfter returns when the current server certificate expires.
+func (r *Reloader) NotAfter() time.Time {
+	return r.current.Load().Certificates[0].Leaf.NotAfter
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stat follows symlinks, so the swap of the ..data link Kubernetes does when
+// it updates a mounted secret counts as a change.
+func (r *Reloader) stat() ([]fileStamp, error) {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+
+	stamp := make([]fileStamp, len(files))
+
+	for i, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+
+		stamp[i] = fileStamp{info.ModTime(), info.Size()}
+	}
+
+	return stamp, nil
+}
+
+func build(cfg Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// CurvePreferences is left unset, so that crypto/tls offers its default
+	// key exchanges, which pick up hybrid post-quantum ones as the toolchain
+	// gains them.
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: cipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	switch cfg.MinVersion {
+	case "1.2":
+		config.MinVersion = tls.VersionTLS12
+	case "1.3":
+		config.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS version %q", cfg.MinVersion)
+	}
+
+	switch cfg.ClientAuth {
+	case ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unsupported client auth mode %q", cfg.ClientAuth)
+	}
+
+	if cfg.ClientAuth != ClientAuthNone {
+		if cfg.ClientCAFile == "" {
+			return nil, errors.New("client certificate verification needs a client CA file")
+		}
+
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", cfg.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
@@ -0,0 +1,336 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate for name, signed by parent, or self-signed
+// when parent is nil.
+func issue(t *testing.T, name string, parent *keyPair, notAfter time.Time) *keyPair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &keyPair{cert, key}
+}
+
+// write stores the pair as PEM files in dir, returning their paths.
+func (p *keyPair) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func (p *keyPair) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{p.cert.Raw}, PrivateKey: p.key, Leaf: p.cert}
+}
+
+// serve starts an HTTPS server using r and returns its URL.
+func serve(t *testing.T, r *Reloader) string {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = r.TLSConfig()
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func client(ca *keyPair, cert *keyPair) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	config := &tls.Config{RootCAs: pool}
+
+	// Sent even when the server asks for another CA, which a client would
+	// not do by itself.
+	if cert != nil {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c := cert.tlsCertificate()
+			return &c, nil
+		}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}}
+}
+
+// servedNotAfter returns the expiry of the certificate the server presents.
+func servedNotAfter(t *testing.T, c *http.Client, url string) time.Time {
+	t.Helper()
+
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0].NotAfter
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issue(t, "ca", nil, time.Now().Add(time.Hour))
+	certFile, keyFile := issue(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+	_, otherKey := issue(t, "other", ca, time.Now().Add(time.Hour)).write(t, dir, "other")
+	caFile, _ := ca.write(t, dir, "ca")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"defaults", Config{CertFile: certFile, KeyFile: keyFile}, ""},
+		{"mutual", Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: ClientAuthRequire, MinVersion: "1.3"}, ""},
+		{"missing file", Config{CertFile: filepath.Join(dir, "nope.crt"), KeyFile: keyFile}, "no such file"},
+		{"mismatched key", Config{CertFile: certFile, KeyFile: otherKey}, "private key does not match"},
+		{"client auth without ca", Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthOptional}, "needs a client CA file"},
+		{"ca without certificates", Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile, ClientAuth: ClientAuthRequire}, "no PEM certificates found"},
+		{"old version", Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"}, `unsupported TLS version "1.1"`},
+		{"unknown client auth", Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: "always"}, `unsupported client auth mode "always"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.cfg)
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, r.TLSConfig())
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issue(t, "ca", nil, time.Now().Add(time.Hour))
+	certFile, keyFile := issue(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := r.current.Load()
+
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+	assert.Nil(t, config.CurvePreferences)
+
+	for _, id := range config.CipherSuites {
+		for _, insecure := range tls.InsecureCipherSuites() {
+			assert.NotEqual(t, insecure.ID, id, insecure.Name)
+		}
+	}
+
+	url := serve(t, r)
+
+	old := client(ca, nil)
+	old.Transport.(*http.Transport).TLSClientConfig.MaxVersion = tls.VersionTLS11
+
+	_, err = old.Get(url)
+	assert.Error(t, err)
+
+	resp, err := client(ca, nil).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, "h2", resp.TLS.NegotiatedProtocol)
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issue(t, "ca", nil, time.Now().Add(time.Hour))
+	stranger := issue(t, "stranger", nil, time.Now().Add(time.Hour))
+
+	certFile, keyFile := issue(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+	caFile, _ := ca.write(t, dir, "ca")
+
+	trusted := issue(t, "orders", ca, time.Now().Add(time.Hour))
+	untrusted := issue(t, "orders", stranger, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		mode   string
+		client *keyPair
+		ok     bool
+	}{
+		{"required and trusted", ClientAuthRequire, trusted, true},
+		{"required and missing", ClientAuthRequire, nil, false},
+		{"required and untrusted", ClientAuthRequire, untrusted, false},
+		{"optional and missing", ClientAuthOptional, nil, true},
+		{"optional and untrusted", ClientAuthOptional, untrusted, false},
+		{"none and untrusted", ClientAuthNone, untrusted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tt.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// TLS 1.3 clients only learn of a rejected certificate when
+			// they read the response, so a full request is made.
+			resp, err := client(ca, tt.client).Get(serve(t, r))
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issue(t, "ca", nil, time.Now().Add(48*time.Hour))
+	first := issue(t, "localhost", ca, time.Now().Add(time.Hour).Truncate(time.Second))
+	second := issue(t, "localhost", ca, time.Now().Add(24*time.Hour).Truncate(time.Second))
+
+	certFile, keyFile := first.write(t, dir, "server")
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := serve(t, r)
+
+	// Each request gets a new connection, so it sees the current certificate.
+	c := client(ca, nil)
+	c.Transport.(*http.Transport).DisableKeepAlives = true
+
+	assert.Equal(t, first.cert.NotAfter, servedNotAfter(t, c, url))
+
+	changed, err := r.Changed()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	second.write(t, dir, "server")
+
+	// The rewrite may land in the same modification time tick as the first
+	// write on coarse file systems, which the size difference does not
+	// always make up for.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err = r.Changed()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, second.cert.NotAfter, servedNotAfter(t, c, url))
+	assert.Equal(t, second.cert.NotAfter, r.NotAfter())
+
+	changed, err = r.Changed()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A broken rotation keeps the current certificate.
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, r.Reload())
+	assert.Equal(t, second.cert.NotAfter, servedNotAfter(t, c, url))
+
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Changed()
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dbStatsCollector reads the pool statistics at scrape time instead of
+// copying them on a timer.
+type dbStatsCollector struct {
+	stats func() map[string]sql.DBStats
+
+	maxOpen, open, inUse, idle         *prometheus.Desc
+	waitCount, waitDuration            *prometheus.Desc
+	closedIdle, closedTime, closedLife *prometheus.Desc
+}
+
+// newDBStatsCollector gives the pool metrics the same const labels as the
+// service's other metrics.
+func newDBStatsCollector(labels prometheus.Labels, stats func() map[string]sql.DBStats) dbStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, []string{"pool"}, labels)
+	}
+
+	return dbStatsCollector{
+		stats:        stats,
+		maxOpen:      desc("db_max_open_connections", "Maximum number of open connections."),
+		open:         desc("db_open_connections", "Established connections, in use and idle."),
+		inUse:        desc("db_in_use_connections", "Connections currently in use."),
+		idle:         desc("db_idle_connections", "Idle connections."),
+		waitCount:    desc("db_wait_count_total", "Connections waited for."),
+		waitDuration: desc("db_wait_duration_seconds_total", "Time spent waiting for a connection."),
+		closedIdle:   desc("db_max_idle_closed_total", "Connections closed due to the idle connection limit."),
+		closedTime:   desc("db_max_idle_time_closed_total", "Connections closed due to the idle time limit."),
+		closedLife:   desc("db_max_lifetime_closed_total", "Connections closed due to the connection lifetime limit."),
+	}
+}
+
+func (c dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.maxOpen, c.open, c.inUse, c.idle, c.waitCount, c.waitDuration, c.closedIdle, c.closedTime, c.closedLife} {
+		ch <- d
+	}
+}
+
+func (c dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for pool, s := range c.stats() {
+		gauge := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, pool)
+		}
+
+		counter := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, pool)
+		}
+
+		gauge(c.maxOpen, float64(s.MaxOpenConnections))
+		gauge(c.open, float64(s.OpenConnections))
+		gauge(c.inUse, float64(s.InUse))
+		gauge(c.idle, float64(s.Idle))
+		counter(c.waitCount, float64(s.WaitCount))
+		counter(c.waitDuration, s.WaitDuration.Seconds())
+		counter(c.closedIdle, float64(s.MaxIdleClosed))
+		counter(c.closedTime, float64(s.MaxIdleTimeClosed))
+		counter(c.closedLife, float64(s.MaxLifetimeClosed))
+	}
+}
@@ -0,0 +1,142 @@
+// Package metrics collects the Prometheus metrics every go-commerce service
+// exposes: HTTP traffic, database pools and queries, rate limiting and
+// recovered panics. It lives outside internal/ so that the other services
+// can import it.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// queryBuckets are finer than prometheus.DefBuckets, since most queries
+// finish in a few milliseconds.
+var queryBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// Metrics owns a registry per service rather than using the global one, so
+// that tests and several servers in one process do not collide.
+//
+// A nil *Metrics is valid and records nothing, which keeps handlers usable in
+// tests that do not care about metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+	labels   prometheus.Labels
+
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	queries     *prometheus.HistogramVec
+	rateLimited prometheus.Counter
+	panics      prometheus.Counter
+}
+
+// New registers the metrics of service, plus the Go runtime and process
+// collectors.
+func New(service string) *Metrics {
+	labels := prometheus.Labels{"service": service}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		labels:   labels,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "http_requests_total",
+			Help:        "HTTP requests by route pattern, method and status.",
+			ConstLabels: labels,
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "http_request_duration_seconds",
+			Help:        "HTTP request latency by route pattern, method and status.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "db_query_duration_seconds",
+			Help:        "Model method latency, by model, method and whether it failed.",
+			ConstLabels: labels,
+			Buckets:     queryBuckets,
+		}, []string{"model", "method", "error"}),
+		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "http_rate_limited_total",
+			Help:        "Requests rejected by the rate limiter.",
+			ConstLabels: labels,
+		}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "http_panics_recovered_total",
+			Help:        "Panics recovered while serving requests.",
+			ConstLabels: labels,
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.queries,
+		m.rateLimited,
+		m.panics,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// methodLabel keeps the method label bounded: clients can send any token as
+// a method, and each distinct one would otherwise become new time series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	code := strconv.Itoa(status)
+	method = methodLabel(method)
+
+	m.requests.WithLabelValues(route, method, code).Inc()
+	m.duration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// ObserveQuery has the signature of data.QueryObserver.
+func (m *Metrics) ObserveQuery(model, method string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.queries.WithLabelValues(model, method, strconv.FormatBool(err != nil)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RateLimited() {
+	if m != nil {
+		m.rateLimited.Inc()
+	}
+}
+
+func (m *Metrics) PanicRecovered() {
+	if m != nil {
+		m.panics.Inc()
+	}
+}
+
+// RegisterDBStats exports the sql.DBStats returned by stats as gauges and
+// counters labelled by pool name. stats is called on every scrape.
+func (m *Metrics) RegisterDBStats(stats func() map[string]sql.DBStats) error {
+	return m.registry.Register(newDBStatsCollector(m.labels, stats))
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	m := New("test")
+
+	m.ObserveRequest("/v1/users/:id", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest("/v1/users/:id", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+	m.ObserveQuery("users", "Get", time.Millisecond, nil)
+	m.ObserveQuery("users", "Get", time.Millisecond, errors.New("boom"))
+	m.RateLimited()
+	m.PanicRecovered()
+
+	err := m.RegisterDBStats(func() map[string]sql.DBStats {
+		return map[string]sql.DBStats{"primary": {MaxOpenConnections: 25, InUse: 3}}
+	})
+	assert.NoError(t, err)
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`http_requests_total{method="GET",route="/v1/users/:id",service="test",status="200"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/v1/users/:id",service="test",status="200"} 2`,
+		`db_query_duration_seconds_count{error="false",method="Get",model="users",service="test"} 1`,
+		`db_query_duration_seconds_count{error="true",method="Get",model="users",service="test"} 1`,
+		`http_rate_limited_total{service="test"} 1`,
+		`http_panics_recovered_total{service="test"} 1`,
+		`db_max_open_connections{pool="primary",service="test"} 25`,
+		`db_in_use_connections{pool="primary",service="test"} 3`,
+		`go_goroutines`,
+	}
+
+	for _, line := range expected {
+		assert.Contains(t, body, line)
+	}
+}
+
+func TestObserveRequestBoundsMethods(t *testing.T) {
+	m := New("test")
+
+	m.ObserveRequest("unmatched", "FOO", http.StatusMethodNotAllowed, time.Millisecond)
+	m.ObserveRequest("unmatched", "BAR", http.StatusMethodNotAllowed, time.Millisecond)
+	m.ObserveRequest("unmatched", http.MethodOptions, http.StatusNoContent, time.Millisecond)
+
+	body := scrape(t, m)
+
+	assert.Contains(t, body, `http_requests_total{method="OTHER",route="unmatched",service="test",status="405"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="OPTIONS",route="unmatched",service="test",status="204"} 1`)
+	assert.NotContains(t, body, `method="FOO"`)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ObserveRequest("/", http.MethodGet, http.StatusOK, time.Second)
+		m.ObserveQuery("users", "Get", time.Second, nil)
+		m.RateLimited()
+		m.PanicRecovered()
+	})
+}
@@ -0,0 +1,155 @@
+// Package tracing sets up OpenTelemetry tracing with W3C trace context
+// propagation, the same way for every go-commerce service.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters lists the values Config.Exporter accepts. "stdout" writes the
+// spans to stderr, since stdout carries the JSON log.
+var Exporters = []string{"none", "stdout", "otlp"}
+
+type Config struct {
+	Service  string
+	Version  string
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector, e.g. "otel-collector:4318". When
+	// empty the OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string
+	// Insecure sends spans to Endpoint over plain HTTP instead of HTTPS.
+	Insecure    bool
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and propagator and returns a
+// function that flushes pending spans. Spans are never exported with "none",
+// but trace context is still propagated so that IDs reach the logs and
+// downstream services.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+
+	switch cfg.Exporter {
+	case "none", "":
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+
+			if cfg.Insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(cfg, exporter)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator())
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider builds a tracer provider that sends spans to exporter, which
+// may be nil. Tests pass a tracetest.InMemoryExporter.
+func NewProvider(cfg Config, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.Service),
+			semconv.ServiceVersion(cfg.Version),
+		)),
+	}
+
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// Transport wraps base, or http.DefaultTransport when nil, so that outgoing
+// requests get a client span and a traceparent header.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return otelhttp.NewTransport(base)
+}
+
+// Inject writes the trace context of ctx through set, e.g. into the headers
+// of a Kafka message.
+func Inject(ctx context.Context, set func(key, value string)) {
+	otel.GetTextMapPropagator().Inject(ctx, funcCarrier{set: set})
+}
+
+// Extract returns ctx with the trace context read through get, e.g. from the
+// headers of a consumed Kafka message.
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, funcCarrier{get: get})
+}
+
+// IDs returns the trace and span IDs of ctx, or empty strings when it carries
+// no valid span.
+func IDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+type funcCarrier struct {
+	get func(string) string
+	set func(string, string)
+}
+
+func (c funcCarrier) Get(key string) string {
+	if c.get == nil {
+		return ""
+	}
+
+	return c.get(key)
+}
+
+func (c funcCarrier) Set(key, value string) {
+	if c.set != nil {
+		c.set(key, value)
+	}
+}
+
+// Keys is only used by propagators that iterate over every header, none of
+// which are configured here.
+func (c funcCarrier) Keys() []string {
+	return nil
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// useProvider installs a provider backed by an in-memory exporter for the
+// duration of the test.
+func useProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator())
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return provider, exporter
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name       string
+		exporter   string
+		expectsErr bool
+	}{
+		{"No exporter", "none", false},
+		{"Default exporter", "", false},
+		{"Stdout exporter", "stdout", false},
+		{"Unknown exporter", "jaeger", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useProvider(t)
+
+			shutdown, err := Setup(context.Background(), Config{Service: "test", Exporter: tc.exporter, SampleRatio: 1})
+			if tc.expectsErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	useProvider(t)
+
+	ctx := Extract(context.Background(), func(key string) string {
+		if key == "traceparent" {
+			return traceparent
+		}
+
+		return ""
+	})
+
+	traceID, spanID := IDs(ctx)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "00f067aa0ba902b7", spanID)
+
+	headers := map[string]string{}
+	Inject(ctx, func(key, value string) { headers[key] = value })
+
+	assert.Equal(t, traceparent, headers["traceparent"])
+}
+
+func TestIDsWithoutSpan(t *testing.T) {
+	traceID, spanID := IDs(context.Background())
+
+	assert.Empty(t, traceID)
+	assert.Empty(t, spanID)
+}
+
+func TestTransport(t *testing.T) {
+	provider, exporter := useProvider(t)
+
+	var received string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "checkout")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected a client span, got %d spans", len(spans))
+	}
+
+	assert.Contains(t, received, span.SpanContext().TraceID().String())
+	assert.Contains(t, received, spans[0].SpanContext.SpanID().String())
+}